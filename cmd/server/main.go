@@ -14,9 +14,17 @@ import (
 	"gwi.com/jedi-team-challenge/internal/api"
 	"gwi.com/jedi-team-challenge/internal/config"
 	"gwi.com/jedi-team-challenge/internal/core"
+	"gwi.com/jedi-team-challenge/internal/core/hub"
+	"gwi.com/jedi-team-challenge/internal/metrics"
+	"gwi.com/jedi-team-challenge/internal/ratelimit"
 	"gwi.com/jedi-team-challenge/internal/store"
 )
 
+// rateLimiterCapacity bounds how many distinct (policy, key) buckets
+// limiter tracks at once, e.g. distinct IPs hitting /login or distinct
+// users posting messages.
+const rateLimiterCapacity = 10000
+
 func main() {
 	// Load configuration
 	config.LoadConfig()
@@ -32,20 +40,20 @@ func main() {
 	flag.Parse()
 
 	// Initialize database store
-	dbStore, err := store.NewSQLiteStore(config.AppConfig.DatabaseURL)
+	dbStore, err := store.NewStore(config.AppConfig.DatabaseDriver, config.AppConfig.DatabaseURL)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer dbStore.Close()
 
-	// Initialize LLM service
-	llmService := core.NewLLMService()
+	// Initialize LLM provider (backend selected via LLM_PROVIDER)
+	llmService := core.NewLLMProvider()
 	defer llmService.Close()
 
 	// Handle data ingestion if flag is set
 	if *ingestDataFlag {
 		log.Println("Starting data ingestion process...")
-		// Pass the GetEmbedding method from LLMService as the embedder function
+		// Pass the GetEmbedding method from the configured LLM provider as the embedder function
 		numIngested, err := dbStore.IngestDataFromFile("data.md", llmService.GetEmbedding)
 		if err != nil {
 			log.Fatalf("Data ingestion failed: %v", err)
@@ -55,18 +63,35 @@ func main() {
 		os.Exit(0) // Exit after ingestion
 	}
 
+	// metricsRegistry is constructed before the services it instruments so it
+	// can be injected into both of them.
+	metricsRegistry := metrics.NewRegistry()
+
 	// Initialize RAG service
-	ragService, err := core.NewRAGService(dbStore, llmService)
+	ragService, err := core.NewRAGService(dbStore, llmService, metricsRegistry)
 	if err != nil {
 		log.Fatalf("Failed to initialize RAG service: %v", err)
 	}
-
-	// Initialize Chat service
-	chatService := core.NewChatService(dbStore, ragService, llmService)
-
-	// Initialize API Handler and Router
-	apiHandler := api.NewAPIHandler(chatService)
-	router := api.NewRouter(apiHandler)
+	ragService.SetToolRegistry(core.NewDefaultToolRegistry(ragService, dbStore))
+
+	// Initialize Chat service. chatHub fans out live chat updates to every
+	// connected WebSocket; swap in a Redis-backed hub.Hub here to scale the
+	// server horizontally without changing ChatService or the WS handler.
+	chatHub := hub.NewInMemoryHub()
+	chatService := core.NewChatService(dbStore, ragService, llmService, chatHub, metricsRegistry)
+
+	// Start the background scheduler that promotes due scheduled messages.
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	scheduler := core.NewMessageScheduler(chatService, dbStore, time.Duration(config.AppConfig.MessageSchedulerIntervalSeconds)*time.Second)
+	go scheduler.Run(schedulerCtx)
+
+	// Initialize API Handler and Router. limiter backs login and chat abuse
+	// controls; swap in a Redis-backed ratelimit.Limiter here to enforce the
+	// same policies across more than one server instance.
+	limiter := ratelimit.NewInMemoryLimiter(rateLimiterCapacity)
+	apiHandler := api.NewAPIHandler(chatService, limiter)
+	router := api.NewRouter(apiHandler, metricsRegistry)
 
 	// Start HTTP server
 	serverAddr := fmt.Sprintf(":%s", config.AppConfig.HTTPPort)
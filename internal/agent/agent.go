@@ -0,0 +1,47 @@
+// Package agent provides a pluggable toolbox that an LLMProvider can
+// advertise to the model during a chat completion, so the model can request
+// a tool call and be fed the result instead of answering from context alone.
+package agent
+
+// ToolSpec describes a single callable tool: its name and description (as
+// advertised to the model), a JSON-schema-shaped Parameters map describing
+// the arguments Impl expects, and the Impl function itself.
+type ToolSpec struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+	Impl        func(args map[string]interface{}) (string, error)
+}
+
+// Registry is the set of tools available to be advertised to the model.
+// Deployments build one at startup via core.NewDefaultToolRegistry and
+// prune it per the ENABLED_TOOLS config.
+type Registry struct {
+	tools map[string]ToolSpec
+}
+
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]ToolSpec)}
+}
+
+func (r *Registry) Register(spec ToolSpec) {
+	r.tools[spec.Name] = spec
+}
+
+func (r *Registry) Get(name string) (ToolSpec, bool) {
+	spec, ok := r.tools[name]
+	return spec, ok
+}
+
+// All returns the registered tools. Order is not significant to callers.
+func (r *Registry) All() []ToolSpec {
+	specs := make([]ToolSpec, 0, len(r.tools))
+	for _, spec := range r.tools {
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+func (r *Registry) Len() int {
+	return len(r.tools)
+}
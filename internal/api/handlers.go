@@ -3,22 +3,59 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"gwi.com/jedi-team-challenge/internal/auth"
+	"gwi.com/jedi-team-challenge/internal/config"
 	"gwi.com/jedi-team-challenge/internal/core"
+	"gwi.com/jedi-team-challenge/internal/core/errs"
+	"gwi.com/jedi-team-challenge/internal/ratelimit"
 	"gwi.com/jedi-team-challenge/internal/store"
 )
 
+// sessionCacheCapacity bounds the in-memory LRU of session revocation
+// checks, trading a little staleness (SessionCacheTTL) for not hitting the
+// store on every authenticated request.
+const sessionCacheCapacity = 10000
+
 type APIHandler struct {
-	chatService *core.ChatService
+	chatService  *core.ChatService
+	sessionCache *auth.SessionCache
+	limiter      ratelimit.Limiter
+	loginBackoff *loginBackoffTracker
+}
+
+func NewAPIHandler(cs *core.ChatService, limiter ratelimit.Limiter) *APIHandler {
+	return &APIHandler{
+		chatService:  cs,
+		sessionCache: auth.NewSessionCache(sessionCacheCapacity),
+		limiter:      limiter,
+		loginBackoff: newLoginBackoffTracker(loginBackoffCapacity),
+	}
 }
 
-func NewAPIHandler(cs *core.ChatService) *APIHandler {
-	return &APIHandler{chatService: cs}
+// sessionRevoked reports whether sessionID is revoked or expired, checking
+// the in-memory cache first and falling back to a store lookup on a miss.
+func (h *APIHandler) sessionRevoked(sessionID string) (bool, error) {
+	if revoked, ok := h.sessionCache.Get(sessionID); ok {
+		return revoked, nil
+	}
+
+	session, err := h.chatService.GetSessionByID(sessionID)
+	if err != nil {
+		return false, err
+	}
+	revoked := session == nil || session.RevokedAt != nil || time.Now().After(session.ExpiresAt)
+	h.sessionCache.Set(sessionID, revoked)
+	return revoked, nil
 }
 
 func (h *APIHandler) JWTAuthMiddleware(next http.Handler) http.Handler {
@@ -30,12 +67,23 @@ func (h *APIHandler) JWTAuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		externalUserID, err := auth.ValidateJWT(tokenString)
-		if err != nil {
+		externalUserID, tokenVersion, sessionID, err := auth.ValidateJWT(tokenString)
+		if err != nil || sessionID == "" {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
+		revoked, err := h.sessionRevoked(sessionID)
+		if err != nil {
+			log.Printf("Error checking session %s: %v", sessionID, err)
+			http.Error(w, "Failed to process session", http.StatusInternalServerError)
+			return
+		}
+		if revoked {
+			http.Error(w, "Session has been revoked, please log in again", http.StatusUnauthorized)
+			return
+		}
+
 		user, err := h.chatService.GetUserByExternalID(externalUserID)
 		if err != nil {
 			log.Printf("Error in JWTAuthMiddleware for user %s: %v", externalUserID, err)
@@ -48,12 +96,38 @@ func (h *APIHandler) JWTAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if user.Disabled {
+			http.Error(w, "Account disabled", http.StatusForbidden)
+			return
+		}
+
+		if tokenVersion != user.TokenVersion {
+			http.Error(w, "Token has been revoked, please log in again", http.StatusUnauthorized)
+			return
+		}
+
 		ctx := context.WithValue(r.Context(), "userID", user.ID)
 		ctx = context.WithValue(ctx, "externalUserID", user.ExternalUserID)
+		ctx = context.WithValue(ctx, "role", user.Role)
+		ctx = context.WithValue(ctx, "sessionID", sessionID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// AdminOnlyMiddleware rejects requests from a non-admin user with 403. It
+// must sit behind JWTAuthMiddleware, which populates the "role" context
+// value it reads.
+func (h *APIHandler) AdminOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, _ := r.Context().Value("role").(string)
+		if role != store.RoleAdmin {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(r.Context()))
+	})
+}
+
 type SignupRequest struct {
 	UserID   string `json:"user_id"`
 	Password string `json:"password"`
@@ -71,6 +145,21 @@ func (h *APIHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !config.AppConfig.AllowPublicSignup {
+		// The very first signup always succeeds even with public signup
+		// disabled, so a fresh deployment can always create its initial admin.
+		existing, _, err := h.chatService.GetUsers(1, "")
+		if err != nil {
+			log.Printf("Error checking for existing users during signup: %v", err)
+			http.Error(w, "Failed to create user", http.StatusInternalServerError)
+			return
+		}
+		if len(existing) > 0 {
+			http.Error(w, "Public signup is disabled", http.StatusForbidden)
+			return
+		}
+	}
+
 	hashedPassword, err := auth.HashPassword(req.Password)
 	if err != nil {
 		log.Printf("Error hashing password for user %s: %v", req.UserID, err)
@@ -94,6 +183,32 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// refreshTokenTTL is how long a session's refresh token is valid for before
+// it must be used (RefreshHandler rotates it on every use, resetting this).
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+// AuthTokens is the body returned by LoginHandler and RefreshHandler.
+// RefreshToken is "<session id>:<opaque secret>"; the session id lets
+// RefreshHandler look up the session row without a separate field, and the
+// secret is what's actually checked against store.Session.RefreshTokenHash.
+// LogoutHandler instead takes the session id from the access token's "sid"
+// claim.
+type AuthTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// clientIP extracts the request's remote address without its port, for
+// recording alongside a new session.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func (h *APIHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -106,6 +221,16 @@ func (h *APIHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// backoffKey is scoped to the (ip, user_id) pair rather than just the
+	// IP, so the flat RateLimitMiddleware policy on this route handles
+	// spraying many accounts from one IP, while this handles repeated
+	// guesses against one account specifically.
+	backoffKey := clientIP(r) + "|" + req.UserID
+	if blocked, retryAfter := h.loginBackoff.blocked(backoffKey); blocked {
+		writeRateLimited(w, r, retryAfter)
+		return
+	}
+
 	user, err := h.chatService.GetUserByExternalID(req.UserID)
 	if err != nil {
 		log.Printf("Error getting user %s: %v", req.UserID, err)
@@ -114,18 +239,192 @@ func (h *APIHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if user == nil || !auth.CheckPasswordHash(req.Password, user.PasswordHash) {
+		h.loginBackoff.recordFailure(backoffKey)
+		log.Printf("AUDIT action=login_failed ip=%s user_id=%q", clientIP(r), req.UserID)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
+	h.loginBackoff.reset(backoffKey)
 
-	token, err := auth.GenerateJWT(req.UserID)
+	if user.Disabled {
+		http.Error(w, "Account disabled", http.StatusForbidden)
+		return
+	}
+
+	secret, err := auth.GenerateRefreshTokenSecret()
+	if err != nil {
+		log.Printf("Error generating refresh token for user %s: %v", req.UserID, err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	session, err := h.chatService.CreateSession(user.ID, auth.HashRefreshTokenSecret(secret), r.UserAgent(), clientIP(r), time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		log.Printf("Error creating session for user %s: %v", req.UserID, err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, err := auth.GenerateJWT(user.ExternalUserID, user.TokenVersion, session.ID)
 	if err != nil {
 		log.Printf("Error generating JWT for user %s: %v", req.UserID, err)
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{"token": token})
+	json.NewEncoder(w).Encode(AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: session.ID + ":" + secret,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+	})
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshHandler exchanges a still-valid refresh token for a new access
+// token, rotating the refresh token itself (invalidating the one passed in)
+// so a leaked-and-later-stolen refresh token can be used at most once by an
+// attacker before the legitimate client's next refresh fails loudly.
+func (h *APIHandler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessionID, secret, ok := strings.Cut(req.RefreshToken, ":")
+	if !ok || sessionID == "" || secret == "" {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := h.chatService.GetSessionByID(sessionID)
+	if err != nil {
+		log.Printf("Error loading session %s: %v", sessionID, err)
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if session == nil || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) ||
+		session.RefreshTokenHash != auth.HashRefreshTokenSecret(secret) {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.chatService.GetUserByID(session.UserID)
+	if err != nil {
+		log.Printf("Error loading user for session %s: %v", sessionID, err)
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if user == nil || user.Disabled {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	newSecret, err := auth.GenerateRefreshTokenSecret()
+	if err != nil {
+		log.Printf("Error generating refresh token for session %s: %v", sessionID, err)
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+	if err := h.chatService.RotateSessionRefreshToken(sessionID, auth.HashRefreshTokenSecret(newSecret), time.Now().Add(refreshTokenTTL)); err != nil {
+		log.Printf("Error rotating session %s: %v", sessionID, err)
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, err := auth.GenerateJWT(user.ExternalUserID, user.TokenVersion, sessionID)
+	if err != nil {
+		log.Printf("Error generating access token for user %s: %v", user.ExternalUserID, err)
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: sessionID + ":" + newSecret,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+	})
+}
+
+// LogoutHandler revokes the session the caller's access token was issued
+// from, so neither it nor its refresh token can be used again.
+func (h *APIHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, _ := r.Context().Value("sessionID").(string)
+	if sessionID == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := h.chatService.RevokeSession(sessionID); err != nil {
+		log.Printf("Error revoking session %s: %v", sessionID, err)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	h.sessionCache.Invalidate(sessionID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAllHandler revokes every session belonging to the caller, signing
+// them out on every device.
+func (h *APIHandler) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int64)
+
+	if err := h.chatService.RevokeAllUserSessions(userID); err != nil {
+		log.Printf("Error revoking sessions for user %d: %v", userID, err)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSessionsHandler lists the caller's own active sessions, so they can
+// recognize (and then terminate via DeleteSessionHandler) one they don't.
+func (h *APIHandler) GetSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int64)
+
+	sessions, err := h.chatService.GetUserSessions(userID)
+	if err != nil {
+		log.Printf("Error listing sessions for user %d: %v", userID, err)
+		http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	active := make([]store.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.RevokedAt == nil && session.ExpiresAt.After(now) {
+			active = append(active, session)
+		}
+	}
+	json.NewEncoder(w).Encode(active)
+}
+
+// DeleteSessionHandler terminates one of the caller's own sessions by ID.
+func (h *APIHandler) DeleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int64)
+	sessionID := chi.URLParam(r, "sessionID")
+
+	session, err := h.chatService.GetSessionByID(sessionID)
+	if err != nil {
+		log.Printf("Error loading session %s: %v", sessionID, err)
+		http.Error(w, "Failed to terminate session", http.StatusInternalServerError)
+		return
+	}
+	if session == nil || session.UserID != userID {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.chatService.RevokeSession(sessionID); err != nil {
+		log.Printf("Error revoking session %s: %v", sessionID, err)
+		http.Error(w, "Failed to terminate session", http.StatusInternalServerError)
+		return
+	}
+	h.sessionCache.Invalidate(sessionID)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 type CreateChatRequest struct {
@@ -164,29 +463,84 @@ func (h *APIHandler) CreateChatHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+const (
+	defaultChatListLimit = 20
+	maxChatListLimit     = 100
+
+	defaultMessagePageLimit = 50
+	maxMessagePageLimit     = 200
+)
+
+// Page is the `{ "cursor": ..., "items": [...] }` envelope returned by every
+// cursor-paginated list endpoint. Cursor is "" once there are no more items.
+type Page struct {
+	Cursor string      `json:"cursor,omitempty"`
+	Items  interface{} `json:"items"`
+}
+
+// parsePageParams reads and validates the `?limit=&cursor=` query params
+// shared by every cursor-paginated endpoint, writing a 400 response and
+// returning ok=false if limit isn't a positive integer. An oversized limit is
+// capped rather than rejected, matching PromptStartersHandler.
+func parsePageParams(w http.ResponseWriter, r *http.Request, defaultLimit, maxLimit int) (limit int, cursor string, ok bool) {
+	limit = defaultLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return 0, "", false
+		}
+		limit = parsed
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return limit, r.URL.Query().Get("cursor"), true
+}
+
 func (h *APIHandler) ListChatsHandler(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(int64)
 
-	chats, err := h.chatService.GetChats(userID)
+	limit, cursor, ok := parsePageParams(w, r, defaultChatListLimit, maxChatListLimit)
+	if !ok {
+		return
+	}
+
+	chats, nextCursor, err := h.chatService.GetChats(userID, limit, cursor)
 	if err != nil {
+		if isInvalidCursorErr(err) {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
 		log.Printf("Error listing chats for user %d: %v", userID, err)
 		http.Error(w, "Failed to list chats", http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(chats)
+	json.NewEncoder(w).Encode(Page{Cursor: nextCursor, Items: chats})
 }
 
 type GetChatDetailsResponse struct {
 	*store.Chat
-	Messages []store.Message `json:"messages"`
+	Messages Page           `json:"messages"`
+	Branches []store.Branch `json:"branches"`
 }
 
 func (h *APIHandler) GetChatDetailsHandler(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(int64)
 	chatID := chi.URLParam(r, "chatID")
+	branchID := r.URL.Query().Get("branch")
+
+	limit, cursor, ok := parsePageParams(w, r, defaultMessagePageLimit, maxMessagePageLimit)
+	if !ok {
+		return
+	}
 
-	chat, messages, err := h.chatService.GetChatDetails(chatID, userID)
+	chat, messages, nextCursor, branches, err := h.chatService.GetChatDetails(chatID, userID, branchID, limit, cursor)
 	if err != nil {
+		if isInvalidCursorErr(err) {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
 		log.Printf("Error getting chat details for user %d, chat %s: %v", userID, chatID, err)
 		http.Error(w, "Failed to get chat details", http.StatusInternalServerError)
 		return
@@ -198,40 +552,256 @@ func (h *APIHandler) GetChatDetailsHandler(w http.ResponseWriter, r *http.Reques
 
 	resp := GetChatDetailsResponse{
 		Chat:     chat,
-		Messages: messages,
+		Messages: Page{Cursor: nextCursor, Items: messages},
+		Branches: branches,
 	}
 	json.NewEncoder(w).Encode(resp)
 }
 
+// isInvalidCursorErr reports whether err came from a malformed client-supplied
+// cursor, as opposed to a genuine storage failure, so handlers can respond
+// 400 instead of 500.
+func isInvalidCursorErr(err error) bool {
+	return strings.Contains(err.Error(), "invalid cursor")
+}
+
+// errorResponse is the JSON body writeError sends for every failure: a
+// stable machine-readable Code, a human-readable Message, and the RequestID
+// of the request that failed, for correlating with server logs.
+type errorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// writeError maps err to an HTTP status by matching it against the
+// internal/core/errs taxonomy via errors.As, defaulting to 500 for anything
+// else, and writes a consistent JSON error body.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		notFound   *errs.NotFound
+		forbidden  *errs.Forbidden
+		conflict   *errs.Conflict
+		validation *errs.Validation
+	)
+
+	status := http.StatusInternalServerError
+	code := "internal_error"
+	message := "Internal server error"
+
+	switch {
+	case errors.As(err, &notFound):
+		status, code, message = http.StatusNotFound, notFound.Code, notFound.Message
+	case errors.As(err, &forbidden):
+		status, code, message = http.StatusForbidden, forbidden.Code, forbidden.Message
+	case errors.As(err, &conflict):
+		status, code, message = http.StatusConflict, conflict.Code, conflict.Message
+	case errors.As(err, &validation):
+		status, code, message = http.StatusBadRequest, validation.Code, validation.Message
+	default:
+		log.Printf("Unhandled error for %s %s: %v", r.Method, r.URL.Path, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{
+		Code:      code,
+		Message:   message,
+		RequestID: requestIDFromContext(r.Context()),
+	})
+}
+
 type PostMessageRequest struct {
 	Content string `json:"content"`
 }
 
+// PostMessageResponse is the JSON body PostMessageHandler returns for the
+// non-streaming reply path: the stored model message, plus how long the
+// request took end-to-end and the token usage the LLM call reported (if the
+// provider surfaced it).
+type PostMessageResponse struct {
+	*store.Message
+	LatencyMs int64            `json:"latency_ms"`
+	Usage     *core.TokenUsage `json:"usage,omitempty"`
+}
+
 func (h *APIHandler) PostMessageHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	userID := r.Context().Value("userID").(int64)
+	chatID := chi.URLParam(r, "chatID")
+
+	req, ok := decodePostMessageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if wantsEventStream(r) {
+		h.postMessageStream(w, r, chatID, userID, req.Content)
+		return
+	}
+
+	modelMessage, usage, err := h.chatService.PostMessage(chatID, userID, req.Content)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(PostMessageResponse{
+		Message:   modelMessage,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Usage:     usage,
+	})
+}
+
+// PostMessageStreamHandler is a dedicated-route alias for PostMessageHandler
+// that always streams the reply over SSE, for clients (e.g. EventSource)
+// that would rather hit a distinct endpoint than content-negotiate via the
+// Accept header or a `?stream=true` query param.
+func (h *APIHandler) PostMessageStreamHandler(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(int64)
 	chatID := chi.URLParam(r, "chatID")
 
+	req, ok := decodePostMessageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	h.postMessageStream(w, r, chatID, userID, req.Content)
+}
+
+// decodePostMessageRequest decodes and validates the request body shared by
+// PostMessageHandler and PostMessageStreamHandler, writing an error response
+// and returning ok=false if either step fails.
+func decodePostMessageRequest(w http.ResponseWriter, r *http.Request) (PostMessageRequest, bool) {
 	var req PostMessageRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
-		return
+		return req, false
 	}
 	if req.Content == "" {
 		http.Error(w, "Message content cannot be empty", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// wantsEventStream reports whether the client asked for a streamed reply,
+// either via the standard Accept header or the `?stream=true` query param
+// (handy for clients like EventSource that can't set custom headers).
+func wantsEventStream(r *http.Request) bool {
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return true
+	}
+	return r.URL.Query().Get("stream") == "true"
+}
+
+// sseEvent is the JSON payload carried by each `data:` line of the stream.
+// Event carries its own "type" discriminator mirrored in the SSE "event:"
+// field so clients can dispatch either way.
+type sseEvent struct {
+	Type      string           `json:"type"`
+	Context   string           `json:"context,omitempty"`
+	Token     string           `json:"token,omitempty"`
+	MessageID string           `json:"message_id,omitempty"`
+	Partial   bool             `json:"partial,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Usage     *core.TokenUsage `json:"usage,omitempty"`
+}
+
+// sseKeepAliveInterval controls how often postMessageStream writes a comment
+// line while waiting on the next event, so intermediaries (proxies, load
+// balancers) that close idle connections don't drop the stream during a slow
+// generation.
+const sseKeepAliveInterval = 15 * time.Second
+
+func (h *APIHandler) postMessageStream(w http.ResponseWriter, r *http.Request, chatID string, userID int64, content string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
 		return
 	}
 
-	modelMessage, err := h.chatService.PostMessage(chatID, userID, req.Content)
+	events, err := h.chatService.PostMessageStream(r.Context(), chatID, userID, content)
 	if err != nil {
-		if err.Error() == "chat not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			log.Printf("Error posting message for user %d, chat %s: %v", userID, chatID, err)
-			http.Error(w, "Failed to post message", http.StatusInternalServerError)
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			var payload sseEvent
+			switch event.Type {
+			case "context":
+				payload = sseEvent{Type: "context", Context: event.Context}
+			case "error":
+				payload = sseEvent{Type: "error", Error: event.Err.Error()}
+			case "done":
+				payload = sseEvent{Type: "done", MessageID: event.MessageID, Partial: event.Partial, Usage: event.Usage}
+			default:
+				payload = sseEvent{Type: "token", Token: event.Token}
+			}
+
+			data, err := json.Marshal(payload)
+			if err != nil {
+				log.Printf("Failed to marshal SSE payload for chat %s: %v", chatID, err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", payload.Type, data)
+			flusher.Flush()
+
+			if payload.Type == "error" || payload.Type == "done" {
+				return
+			}
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+const (
+	defaultPromptStarterLimit = 5
+	maxPromptStarterLimit     = 20
+)
+
+// PromptStartersHandler returns a handful of suggested opening questions,
+// generated from a sample of the ingested corpus, for the frontend to show
+// on a new/empty chat screen.
+func (h *APIHandler) PromptStartersHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultPromptStarterLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
 		}
+		limit = parsed
+	}
+	if limit > maxPromptStarterLimit {
+		limit = maxPromptStarterLimit
+	}
+
+	starters, err := h.chatService.GetPromptStarters(limit)
+	if err != nil {
+		log.Printf("Error generating prompt starters: %v", err)
+		http.Error(w, "Failed to generate prompt starters", http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(modelMessage)
+	json.NewEncoder(w).Encode(starters)
 }
 
 type FeedbackRequest struct {
@@ -250,12 +820,243 @@ func (h *APIHandler) MessageFeedbackHandler(w http.ResponseWriter, r *http.Reque
 
 	err := h.chatService.SetMessageFeedback(messageID, userID, req.Negative)
 	if err != nil {
-		if err.Error() == "message not found for feedback" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			log.Printf("Error setting feedback for message %s by user %d: %v", messageID, userID, err)
-			http.Error(w, "Failed to set feedback", http.StatusInternalServerError)
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type EditMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// EditMessageHandler replaces a prior user message with a new one on a fresh
+// branch and regenerates the assistant reply against it, leaving the
+// original branch untouched.
+func (h *APIHandler) EditMessageHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int64)
+	messageID := chi.URLParam(r, "messageID")
+
+	var req EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Content == "" {
+		http.Error(w, "Message content cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	modelMessage, err := h.chatService.EditMessage(messageID, userID, req.Content)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(modelMessage)
+}
+
+type ScheduleMessageRequest struct {
+	Content     string    `json:"content"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+}
+
+// ScheduleMessageHandler queues a message for delayed delivery instead of
+// posting it immediately; MessageScheduler promotes it into a live message,
+// with a generated reply, once ScheduledAt arrives.
+func (h *APIHandler) ScheduleMessageHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int64)
+	chatID := chi.URLParam(r, "chatID")
+
+	var req ScheduleMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Content == "" {
+		http.Error(w, "Message content cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if !req.ScheduledAt.After(time.Now()) {
+		http.Error(w, "scheduled_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	msg, err := h.chatService.ScheduleMessage(chatID, userID, req.Content, req.ScheduledAt)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(msg)
+}
+
+// CancelScheduledMessageHandler withdraws a message queued with
+// ScheduleMessageHandler before MessageScheduler delivers it.
+func (h *APIHandler) CancelScheduledMessageHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int64)
+	messageID := chi.URLParam(r, "messageID")
+
+	if err := h.chatService.CancelScheduledMessage(messageID, userID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteMessageHandler soft-deletes a message, leaving its place in the
+// conversation tree intact but tombstoning its content on every later read.
+func (h *APIHandler) DeleteMessageHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int64)
+	messageID := chi.URLParam(r, "messageID")
+
+	if err := h.chatService.DeleteMessage(messageID, userID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+const (
+	defaultUserListLimit = 20
+	maxUserListLimit     = 100
+)
+
+// ListUsersHandler returns a cursor-paginated page of user accounts, for an
+// admin's user management screen.
+func (h *APIHandler) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, cursor, ok := parsePageParams(w, r, defaultUserListLimit, maxUserListLimit)
+	if !ok {
+		return
+	}
+
+	users, nextCursor, err := h.chatService.GetUsers(limit, cursor)
+	if err != nil {
+		if isInvalidCursorErr(err) {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error listing users: %v", err)
+		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(Page{Cursor: nextCursor, Items: users})
+}
+
+// adminTargetUserID parses the {userID} URL param shared by the admin user
+// endpoints, writing a 400 response and returning ok=false if it isn't a
+// valid integer.
+func adminTargetUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid userID parameter", http.StatusBadRequest)
+		return 0, false
+	}
+	return userID, true
+}
+
+type SetUserDisabledRequest struct {
+	Disabled bool `json:"disabled"`
+}
+
+// SetUserDisabledHandler enables or disables another user's account.
+func (h *APIHandler) SetUserDisabledHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := adminTargetUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req SetUserDisabledRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.chatService.SetUserDisabled(userID, req.Disabled); err != nil {
+		log.Printf("Error setting disabled=%v for user %d: %v", req.Disabled, userID, err)
+		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ResetUserPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// ResetUserPasswordHandler sets another user's password without requiring
+// their current one.
+func (h *APIHandler) ResetUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := adminTargetUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req ResetUserPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Password == "" {
+		http.Error(w, "Password is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.chatService.ResetUserPassword(userID, req.Password); err != nil {
+		log.Printf("Error resetting password for user %d: %v", userID, err)
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForceLogoutUserHandler invalidates every JWT already issued to a user and
+// revokes their sessions, forcing them to log in again on every device.
+func (h *APIHandler) ForceLogoutUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := adminTargetUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.chatService.ForceLogoutUser(userID); err != nil {
+		log.Printf("Error forcing logout for user %d: %v", userID, err)
+		http.Error(w, "Failed to log out user", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListUserChatsHandler lists another user's chats, for admin support/review.
+func (h *APIHandler) ListUserChatsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := adminTargetUserID(w, r)
+	if !ok {
+		return
+	}
+
+	limit, cursor, ok := parsePageParams(w, r, defaultChatListLimit, maxChatListLimit)
+	if !ok {
+		return
+	}
+
+	chats, nextCursor, err := h.chatService.GetChats(userID, limit, cursor)
+	if err != nil {
+		if isInvalidCursorErr(err) {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
 		}
+		log.Printf("Error listing chats for user %d: %v", userID, err)
+		http.Error(w, "Failed to list chats", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(Page{Cursor: nextCursor, Items: chats})
+}
+
+// DeleteUserChatHandler deletes any chat by ID, regardless of its owner.
+func (h *APIHandler) DeleteUserChatHandler(w http.ResponseWriter, r *http.Request) {
+	chatID := chi.URLParam(r, "chatID")
+
+	if err := h.chatService.DeleteUserChat(chatID); err != nil {
+		writeError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// loginBackoffCapacity bounds the in-memory LRU of (ip, user_id) failure
+// counters, evicting the least-recently-used pair once full.
+const loginBackoffCapacity = 10000
+
+// loginBackoffBase and loginBackoffMax bound the exponential delay imposed
+// after each consecutive failed login for the same (ip, user_id) pair: 1s,
+// 2s, 4s, ... capped at loginBackoffMax.
+const (
+	loginBackoffBase = 1 * time.Second
+	loginBackoffMax  = 5 * time.Minute
+
+	// loginBackoffMaxShift bounds the exponent used to compute the backoff
+	// delay. 1s<<9 already exceeds loginBackoffMax, so this is far more
+	// headroom than the cap ever needs - it exists only to stop an
+	// attacker who racks up enough consecutive failures from overflowing
+	// the int64 shift into a negative delay that would slip past the
+	// loginBackoffMax cap below and silently disable the backoff.
+	loginBackoffMaxShift = 32
+)
+
+type loginBackoffEntry struct {
+	key          string
+	failures     int
+	blockedUntil time.Time
+}
+
+// loginBackoffTracker grows the wait a caller must observe between login
+// attempts exponentially with each consecutive failure against the same
+// (ip, user_id) pair, on top of RateLimitMiddleware's flat per-IP cap, so a
+// credential-stuffing attempt against one account slows down even while
+// staying under the per-IP request rate.
+type loginBackoffTracker struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+func newLoginBackoffTracker(capacity int) *loginBackoffTracker {
+	return &loginBackoffTracker{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// blocked reports whether key is still within a backoff window opened by a
+// prior failure.
+func (t *loginBackoffTracker) blocked(key string) (blocked bool, retryAfter time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	el, ok := t.items[key]
+	if !ok {
+		return false, 0
+	}
+	t.ll.MoveToFront(el)
+
+	wait := time.Until(el.Value.(*loginBackoffEntry).blockedUntil)
+	if wait <= 0 {
+		return false, 0
+	}
+	return true, wait
+}
+
+// recordFailure increments key's consecutive failure count and extends its
+// backoff window exponentially, evicting the least-recently-used key if the
+// tracker is at capacity.
+func (t *loginBackoffTracker) recordFailure(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	el, ok := t.items[key]
+	if !ok {
+		el = t.ll.PushFront(&loginBackoffEntry{key: key})
+		t.items[key] = el
+		if t.ll.Len() > t.capacity {
+			oldest := t.ll.Back()
+			t.ll.Remove(oldest)
+			delete(t.items, oldest.Value.(*loginBackoffEntry).key)
+		}
+	} else {
+		t.ll.MoveToFront(el)
+	}
+
+	entry := el.Value.(*loginBackoffEntry)
+	entry.failures++
+	shift := entry.failures - 1
+	if shift > loginBackoffMaxShift {
+		shift = loginBackoffMaxShift
+	}
+	delay := loginBackoffBase * time.Duration(1<<uint(shift))
+	if delay > loginBackoffMax {
+		delay = loginBackoffMax
+	}
+	entry.blockedUntil = time.Now().Add(delay)
+}
+
+// reset clears key's failure count after a successful login.
+func (t *loginBackoffTracker) reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if el, ok := t.items[key]; ok {
+		t.ll.Remove(el)
+		delete(t.items, key)
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gwi.com/jedi-team-challenge/internal/ratelimit"
+)
+
+var (
+	// loginRateLimitPolicy caps login attempts per IP, independent of which
+	// account is being attempted, to blunt password-spraying across users.
+	loginRateLimitPolicy = ratelimit.Policy{Name: "login_per_ip", Limit: 5, Window: time.Minute}
+
+	// postMessageRateLimitPolicy caps how often one user can trigger an LLM
+	// call, shared across the HTTP, streaming, scheduling, and WebSocket
+	// entry points since all of them do the same expensive work.
+	postMessageRateLimitPolicy = ratelimit.Policy{Name: "messages_per_user", Limit: 60, Window: time.Hour}
+
+	// createChatRateLimitPolicy caps how many new chats one user can start
+	// per day.
+	createChatRateLimitPolicy = ratelimit.Policy{Name: "chat_creation_per_user", Limit: 10, Window: 24 * time.Hour}
+)
+
+// RateLimitMiddleware rejects a request with 429 once keyFunc(r) has
+// exceeded policy, setting Retry-After and a JSON body matching
+// errorResponse. keyFunc lets the same middleware limit by client IP (for
+// unauthenticated routes like /login) or by user ID (for routes behind
+// JWTAuthMiddleware, where it's already in context).
+func (h *APIHandler) RateLimitMiddleware(policy ratelimit.Policy, keyFunc func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if allowed, retryAfter := h.limiter.Allow(keyFunc(r), policy); !allowed {
+				writeRateLimited(w, r, retryAfter)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeRateLimited writes a 429 response with Retry-After and a JSON body
+// consistent with writeError's errorResponse shape, shared by
+// RateLimitMiddleware and LoginHandler's exponential backoff check.
+func writeRateLimited(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second).Seconds())+1))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(errorResponse{
+		Code:      "rate_limited",
+		Message:   "Too many requests, please try again later",
+		RequestID: requestIDFromContext(r.Context()),
+	})
+}
+
+// ipRateLimitKey rate-limits by client IP, for routes that run before a user
+// is authenticated.
+func ipRateLimitKey(r *http.Request) string {
+	return clientIP(r)
+}
+
+// userRateLimitKey rate-limits by user ID, for routes behind
+// JWTAuthMiddleware.
+func userRateLimitKey(r *http.Request) string {
+	userID, _ := r.Context().Value("userID").(int64)
+	return strconv.FormatInt(userID, 10)
+}
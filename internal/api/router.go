@@ -1,42 +1,113 @@
 package api
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"gwi.com/jedi-team-challenge/internal/config"
+	"gwi.com/jedi-team-challenge/internal/metrics"
 )
 
-func NewRouter(apiHandler *APIHandler) http.Handler {
+func NewRouter(apiHandler *APIHandler, metricsRegistry *metrics.Registry) http.Handler {
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)       // Basic request logging
-	r.Use(middleware.Recoverer)    // Recover from panics
-	r.Use(middleware.StripSlashes) // Ensure consistent path handling
+	r.Use(RequestIDMiddleware)        // Assigns X-Request-ID for log correlation
+	r.Use(middleware.Logger)          // Basic request logging
+	r.Use(middleware.Recoverer)       // Recover from panics
+	r.Use(middleware.StripSlashes)    // Ensure consistent path handling
+	r.Use(metricsRegistry.Middleware) // Per-request latency metrics
+
+	// Scrape endpoint for Prometheus, outside the API prefix and auth group.
+	r.With(metricsAuthMiddleware).Get("/metrics", metricsRegistry.Handler())
 
 	// All API routes will be under /api
 	r.Route("/api", func(r chi.Router) {
 		// Public routes
-		r.Post("/login", apiHandler.LoginHandler)
+		r.With(apiHandler.RateLimitMiddleware(loginRateLimitPolicy, ipRateLimitKey)).Post("/login", apiHandler.LoginHandler)
+		r.Post("/signup", apiHandler.SignupHandler)
+		r.Post("/auth/refresh", apiHandler.RefreshHandler)
 		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte(`{"status":"ok"}`))
 		})
 
+		// WebSocket chat transport. This can't sit behind JWTAuthMiddleware
+		// since browsers can't set headers on a WS handshake; it validates the
+		// JWT itself from the `token` query parameter instead.
+		r.Get("/chats/{chatID}/ws", apiHandler.ChatWebSocketHandler)
+
 		// User-authenticated routes
 		r.Group(func(r chi.Router) {
 			r.Use(apiHandler.JWTAuthMiddleware)
 
 			// Chat routes
-			r.Post("/chats", apiHandler.CreateChatHandler)
+			r.With(apiHandler.RateLimitMiddleware(createChatRateLimitPolicy, userRateLimitKey)).Post("/chats", apiHandler.CreateChatHandler)
 			r.Get("/chats", apiHandler.ListChatsHandler)
 			r.Get("/chats/{chatID}", apiHandler.GetChatDetailsHandler)
-			r.Post("/chats/{chatID}/messages", apiHandler.PostMessageHandler)
+
+			r.With(apiHandler.RateLimitMiddleware(postMessageRateLimitPolicy, userRateLimitKey)).Post("/chats/{chatID}/messages", apiHandler.PostMessageHandler)
+			r.With(apiHandler.RateLimitMiddleware(postMessageRateLimitPolicy, userRateLimitKey)).Post("/chats/{chatID}/messages/stream", apiHandler.PostMessageStreamHandler)
+			r.With(apiHandler.RateLimitMiddleware(postMessageRateLimitPolicy, userRateLimitKey)).Post("/chats/{chatID}/messages/schedule", apiHandler.ScheduleMessageHandler)
 
 			// Message feedback route
 			r.Post("/messages/{messageID}/feedback", apiHandler.MessageFeedbackHandler)
+
+			// Edit a message and regenerate the reply on a new branch
+			r.Post("/messages/{messageID}/edit", apiHandler.EditMessageHandler)
+
+			// Soft-delete a message, tombstoning it in place
+			r.Delete("/messages/{messageID}", apiHandler.DeleteMessageHandler)
+
+			// Cancel a message scheduled for delayed delivery
+			r.Delete("/messages/{messageID}/schedule", apiHandler.CancelScheduledMessageHandler)
+
+			// Prompt starters for new chats
+			r.Get("/prompt-starters", apiHandler.PromptStartersHandler)
+
+			// Session management for the caller's own account
+			r.Post("/auth/logout", apiHandler.LogoutHandler)
+			r.Post("/auth/logout-all", apiHandler.LogoutAllHandler)
+			r.Get("/auth/sessions", apiHandler.GetSessionsHandler)
+			r.Delete("/auth/sessions/{sessionID}", apiHandler.DeleteSessionHandler)
+
+			// Admin-only user management routes
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(apiHandler.AdminOnlyMiddleware)
+
+				r.Get("/users", apiHandler.ListUsersHandler)
+				r.Patch("/users/{userID}/disabled", apiHandler.SetUserDisabledHandler)
+				r.Post("/users/{userID}/reset-password", apiHandler.ResetUserPasswordHandler)
+				r.Post("/users/{userID}/logout", apiHandler.ForceLogoutUserHandler)
+				r.Get("/users/{userID}/chats", apiHandler.ListUserChatsHandler)
+				r.Delete("/chats/{chatID}", apiHandler.DeleteUserChatHandler)
+			})
 		})
 	})
 
 	return r
 }
+
+// metricsAuthMiddleware gates /metrics behind METRICS_TOKEN when one is
+// configured, so a deployment can keep a scrape endpoint that can reveal
+// chat/LLM activity volume from being open to anyone. If METRICS_TOKEN is
+// unset, the endpoint stays open, matching this server's pre-existing
+// behavior.
+func metricsAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := config.AppConfig.MetricsToken
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			http.Error(w, "Invalid or missing metrics token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"gwi.com/jedi-team-challenge/internal/auth"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Auth happens via the `token` query parameter, not cookies, so allowing
+	// any origin here doesn't weaken the JWT check below.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsClientMessage is an envelope sent by the client over the chat WebSocket.
+type wsClientMessage struct {
+	Type      string `json:"type"` // "message", "cancel", or "feedback"
+	Content   string `json:"content,omitempty"`
+	MessageID string `json:"message_id,omitempty"`
+	Negative  bool   `json:"negative,omitempty"`
+}
+
+// wsErrorEvent is the envelope for a failure local to this connection (e.g.
+// an unrecognized client frame), as opposed to a core.ChatEvent relayed from
+// the hub, which every device connected to the chat receives identically.
+type wsErrorEvent struct {
+	Type  string `json:"type"` // always "error"
+	Error string `json:"error"`
+}
+
+// ChatWebSocketHandler upgrades to a WebSocket for chatID after validating
+// the JWT passed in the `token` query parameter, since browsers can't set an
+// Authorization header on a WebSocket handshake. It reuses ChatService's
+// streaming PostMessage logic, so every device with a connection open on the
+// same chat sees tokens and title updates live, without polling.
+func (h *APIHandler) ChatWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	chatID := chi.URLParam(r, "chatID")
+
+	tokenString := r.URL.Query().Get("token")
+	if tokenString == "" {
+		http.Error(w, "token query parameter is required", http.StatusUnauthorized)
+		return
+	}
+
+	externalUserID, tokenVersion, sessionID, err := auth.ValidateJWT(tokenString)
+	if err != nil || sessionID == "" {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	revoked, err := h.sessionRevoked(sessionID)
+	if err != nil {
+		log.Printf("Error checking session %s for chat websocket %s: %v", sessionID, chatID, err)
+		http.Error(w, "Failed to process session", http.StatusInternalServerError)
+		return
+	}
+	if revoked {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.chatService.GetUserByExternalID(externalUserID)
+	if err != nil {
+		log.Printf("Error resolving user for chat websocket %s: %v", chatID, err)
+		http.Error(w, "Failed to process user identity", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+	if user.Disabled || tokenVersion != user.TokenVersion {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade websocket for chat %s: %v", chatID, err)
+		return
+	}
+	defer conn.Close()
+
+	newChatWSSession(h, conn, chatID, user.ID).run()
+}
+
+const (
+	wsWriteWait      = 10 * time.Second    // time allowed to write a message or ping to the peer
+	wsPongWait       = 60 * time.Second    // time allowed to read the next pong before the connection is considered dead
+	wsPingPeriod     = wsPongWait * 9 / 10 // must be less than wsPongWait
+	wsOutboxCapacity = 32                  // buffered events a slow client can fall behind by before being dropped
+)
+
+// chatWSSession manages one authenticated WebSocket connection to a chat. It
+// subscribes to the chat's hub topic for the connection's lifetime, so every
+// message, streamed token, feedback change, or title update - whichever
+// device or API call triggered it - reaches this connection the same way it
+// reaches every other device connected to the same chat. A single writer
+// goroutine owns the connection and sends its pings itself; hub events and
+// local errors are funneled to it through a bounded outbox so a slow client
+// is dropped from rather than allowed to block publishers.
+type chatWSSession struct {
+	handler *APIHandler
+	conn    *websocket.Conn
+	chatID  string
+	userID  int64
+
+	outbox chan []byte
+
+	genMu     sync.Mutex
+	cancelGen context.CancelFunc
+	genWG     sync.WaitGroup // generations started by handleUserMessage, which may send to outbox
+}
+
+func newChatWSSession(h *APIHandler, conn *websocket.Conn, chatID string, userID int64) *chatWSSession {
+	return &chatWSSession{
+		handler: h,
+		conn:    conn,
+		chatID:  chatID,
+		userID:  userID,
+		outbox:  make(chan []byte, wsOutboxCapacity),
+	}
+}
+
+func (s *chatWSSession) run() {
+	events, unsubscribe := s.handler.chatService.SubscribeChatEvents(s.chatID)
+	defer s.cancelActive()
+
+	forwardDone := make(chan struct{})
+	go func() {
+		defer close(forwardDone)
+		s.forwardHubEvents(events)
+	}()
+
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		s.writeLoop()
+	}()
+
+	s.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	s.conn.SetPongHandler(func(string) error {
+		s.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		return nil
+	})
+
+	for {
+		var msg wsClientMessage
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			break
+		}
+
+		switch msg.Type {
+		case "message":
+			s.handleUserMessage(msg.Content)
+		case "cancel":
+			s.cancelActive()
+		case "feedback":
+			s.handleFeedback(msg.MessageID, msg.Negative)
+		default:
+			s.enqueueLocal(wsErrorEvent{Type: "error", Error: "unknown message type: " + msg.Type})
+		}
+	}
+
+	// unsubscribe() closes events, which stops forwardHubEvents, and the
+	// in-flight generation is cancelled and waited for; only once both have
+	// returned is it safe to close s.outbox, since they are the only other
+	// goroutines that ever send to it.
+	unsubscribe()
+	<-forwardDone
+	s.cancelActive()
+	s.genWG.Wait()
+	close(s.outbox)
+	<-writerDone
+}
+
+// forwardHubEvents relays every event published for this session's chat into
+// the connection's own outbox, dropping it if the outbox is already full
+// rather than letting a slow client stall the hub subscription.
+func (s *chatWSSession) forwardHubEvents(events <-chan []byte) {
+	for event := range events {
+		select {
+		case s.outbox <- event:
+		default:
+			log.Printf("Dropping chat event for slow websocket consumer on chat %s", s.chatID)
+		}
+	}
+}
+
+// writeLoop is the connection's only writer: it drains the outbox and sends
+// periodic pings, so every write (and the deadline protecting it) happens on
+// one goroutine as gorilla/websocket requires.
+func (s *chatWSSession) writeLoop() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-s.outbox:
+			s.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				s.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := s.conn.WriteMessage(websocket.TextMessage, event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			s.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleUserMessage starts streaming a reply to content in the background,
+// cancelling any generation already in flight on this connection first.
+// Every event it produces is published to the hub by ChatService, so this
+// connection (and every other device on the same chat) sees it the same way
+// as any event from another source.
+func (s *chatWSSession) handleUserMessage(content string) {
+	if content == "" {
+		s.enqueueLocal(wsErrorEvent{Type: "error", Error: "message content cannot be empty"})
+		return
+	}
+
+	userKey := strconv.FormatInt(s.userID, 10)
+	if allowed, retryAfter := s.handler.limiter.Allow(userKey, postMessageRateLimitPolicy); !allowed {
+		s.enqueueLocal(wsErrorEvent{Type: "error", Error: "rate limited, retry after " + retryAfter.Round(time.Second).String()})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.genMu.Lock()
+	if s.cancelGen != nil {
+		s.cancelGen()
+	}
+	s.cancelGen = cancel
+	s.genMu.Unlock()
+
+	s.genWG.Add(1)
+	go func() {
+		defer s.genWG.Done()
+		defer cancel()
+		if err := s.handler.chatService.StreamMessageToChat(ctx, s.chatID, s.userID, content); err != nil {
+			s.enqueueLocal(wsErrorEvent{Type: "error", Error: err.Error()})
+		}
+	}()
+}
+
+func (s *chatWSSession) handleFeedback(messageID string, negative bool) {
+	if messageID == "" {
+		s.enqueueLocal(wsErrorEvent{Type: "error", Error: "message_id is required"})
+		return
+	}
+	if err := s.handler.chatService.SetMessageFeedback(messageID, s.userID, negative); err != nil {
+		s.enqueueLocal(wsErrorEvent{Type: "error", Error: "failed to set feedback"})
+	}
+}
+
+// cancelActive aborts whichever generation is currently running on this
+// connection's shared context, if any.
+func (s *chatWSSession) cancelActive() {
+	s.genMu.Lock()
+	defer s.genMu.Unlock()
+	if s.cancelGen != nil {
+		s.cancelGen()
+		s.cancelGen = nil
+	}
+}
+
+// enqueueLocal delivers an event that's relevant only to this connection
+// (not published to the hub), dropping it if the outbox is full.
+func (s *chatWSSession) enqueueLocal(event wsErrorEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal websocket event for chat %s: %v", s.chatID, err)
+		return
+	}
+	select {
+	case s.outbox <- data:
+	default:
+		log.Printf("Dropping local event for slow websocket consumer on chat %s", s.chatID)
+	}
+}
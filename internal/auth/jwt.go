@@ -8,18 +8,36 @@ import (
 	"gwi.com/jedi-team-challenge/internal/config"
 )
 
-func GenerateJWT(userID string) (string, error) {
+// AccessTokenTTL is how long an access token minted by GenerateJWT is valid.
+// It's intentionally short since RefreshHandler can mint a new one cheaply,
+// and a short-lived access token limits how long a leaked one stays useful.
+const AccessTokenTTL = 15 * time.Minute
+
+// GenerateJWT issues an access token for userID carrying tokenVersion as the
+// "tv" claim and sessionID as the "sid" claim. JWTAuthMiddleware rejects the
+// token once tokenVersion no longer matches the user's current
+// store.User.TokenVersion (BumpUserTokenVersion), and separately once the
+// session named by "sid" is revoked or expired (RevokeSession), so either
+// mechanism alone is enough to force a logout before the token's natural
+// expiry. ValidateJWT itself only checks the signature and expiry.
+func GenerateJWT(userID string, tokenVersion int, sessionID string) (string, error) {
 	claims := jwt.MapClaims{
 		"sub": userID,
+		"tv":  tokenVersion,
+		"sid": sessionID,
 		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
+		"exp": time.Now().Add(AccessTokenTTL).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(config.AppConfig.JWTSecret))
 }
 
-func ValidateJWT(tokenString string) (string, error) {
+// ValidateJWT returns the subject, token version, and session ID embedded in
+// tokenString. Tokens issued before the "tv"/"sid" claims existed decode them
+// as 0/"", matching the zero value of a freshly migrated User.TokenVersion
+// and signalling "no session" respectively.
+func ValidateJWT(tokenString string) (userID string, tokenVersion int, sessionID string, err error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -28,12 +46,20 @@ func ValidateJWT(tokenString string) (string, error) {
 	})
 
 	if err != nil {
-		return "", err
+		return "", 0, "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return "", 0, "", fmt.Errorf("invalid token")
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims["sub"].(string), nil
+	if tv, ok := claims["tv"].(float64); ok {
+		tokenVersion = int(tv)
+	}
+	if sid, ok := claims["sid"].(string); ok {
+		sessionID = sid
 	}
 
-	return "", fmt.Errorf("invalid token")
+	return claims["sub"].(string), tokenVersion, sessionID, nil
 }
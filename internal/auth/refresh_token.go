@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// refreshTokenSecretBytes is the amount of randomness in a refresh token's
+// secret half, ample to make it infeasible to guess.
+const refreshTokenSecretBytes = 32
+
+// GenerateRefreshTokenSecret returns a fresh, high-entropy opaque secret for
+// a session's refresh token. Callers store only HashRefreshTokenSecret's
+// output; the secret itself is returned to the client once and never again.
+func GenerateRefreshTokenSecret() (string, error) {
+	buf := make([]byte, refreshTokenSecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token secret: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashRefreshTokenSecret hashes a refresh token secret for storage in
+// store.Session.RefreshTokenHash. Unlike password hashing, the secret is
+// already high-entropy, so a fast, unsalted hash is enough to avoid ever
+// storing it in recoverable form.
+func HashRefreshTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// SessionCacheTTL bounds how stale a cached revocation check can be: after a
+// session is revoked, a request whose "sid" is still cached can succeed for
+// up to this long before the revocation takes effect. RevokeSession callers
+// should call Invalidate on the session they just revoked to avoid waiting
+// out the TTL for that one session.
+const SessionCacheTTL = 30 * time.Second
+
+type sessionCacheEntry struct {
+	sessionID string
+	revoked   bool
+	expiresAt time.Time
+}
+
+// SessionCache is a bounded least-recently-used cache of session revocation
+// status, checked by JWTAuthMiddleware and ChatWebSocketHandler before
+// falling back to a store.GetSessionByID lookup on every request.
+type SessionCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+// NewSessionCache returns a SessionCache holding at most capacity entries,
+// evicting the least-recently-used one once full.
+func NewSessionCache(capacity int) *SessionCache {
+	return &SessionCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached revoked status for sessionID, and ok=false if
+// there's no unexpired entry.
+func (c *SessionCache) Get(sessionID string) (revoked bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, found := c.items[sessionID]
+	if !found {
+		return false, false
+	}
+	entry := el.Value.(*sessionCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.items, sessionID)
+		return false, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.revoked, true
+}
+
+// Set records sessionID's revoked status for SessionCacheTTL, evicting the
+// least-recently-used entry if the cache is at capacity.
+func (c *SessionCache) Set(sessionID string, revoked bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(SessionCacheTTL)
+	if el, found := c.items[sessionID]; found {
+		entry := el.Value.(*sessionCacheEntry)
+		entry.revoked = revoked
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	el := c.ll.PushFront(&sessionCacheEntry{sessionID: sessionID, revoked: revoked, expiresAt: expiresAt})
+	c.items[sessionID] = el
+
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*sessionCacheEntry).sessionID)
+	}
+}
+
+// Invalidate immediately drops sessionID from the cache, so the next request
+// bearing it is re-checked against the store rather than waiting out
+// SessionCacheTTL. Call this right after revoking a session.
+func (c *SessionCache) Invalidate(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, found := c.items[sessionID]; found {
+		c.ll.Remove(el)
+		delete(c.items, sessionID)
+	}
+}
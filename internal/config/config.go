@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -14,6 +15,42 @@ type Config struct {
 	HTTPPort     string
 	LogLevel     string
 	JWTSecret    string
+
+	// AllowPublicSignup gates /signup for everyone after the first account.
+	// The very first signup always succeeds regardless of this flag, so a
+	// fresh deployment can always create its initial admin.
+	AllowPublicSignup bool
+
+	// DatabaseDriver selects the store.Store backend: "sqlite3" (default) or
+	// "postgres". DatabaseURL is interpreted as that driver's DSN.
+	DatabaseDriver string
+
+	// LLMProvider/EmbeddingProvider select which backend implements
+	// core.LLMProvider for chat completions/titles and embeddings
+	// respectively. One of "gemini", "openai", "anthropic", "ollama".
+	// EmbeddingProvider defaults to LLMProvider when unset.
+	LLMProvider       string
+	LLMModel          string
+	EmbeddingProvider string
+	EmbeddingModel    string
+
+	OpenAIAPIKey    string
+	OpenAIBaseURL   string
+	AnthropicAPIKey string
+	OllamaBaseURL   string
+
+	// EnabledTools is a comma-separated list of agent tool names to advertise
+	// to the model (e.g. "search_market_data,current_time"). Empty enables
+	// every registered tool.
+	EnabledTools string
+
+	// MessageSchedulerIntervalSeconds controls how often core.MessageScheduler
+	// polls for due scheduled messages.
+	MessageSchedulerIntervalSeconds int
+
+	// MetricsToken, if set, gates /metrics behind a bearer token so scrape
+	// access can be restricted; if empty, /metrics stays open.
+	MetricsToken string
 }
 
 var AppConfig Config
@@ -25,20 +62,79 @@ func LoadConfig() {
 	}
 
 	AppConfig = Config{
-		GeminiAPIKey: getEnv("GEMINI_API_KEY", ""),
-		DatabaseURL:  getEnv("DATABASE_URL", "gwi_chatbot.db"),
-		HTTPPort:     getEnv("HTTP_PORT", "8080"),
-		LogLevel:     getEnv("LOG_LEVEL", "INFO"),
-		JWTSecret:    getEnv("JWT_SECRET", ""),
-	}
+		GeminiAPIKey:   getEnv("GEMINI_API_KEY", ""),
+		DatabaseURL:    getEnv("DATABASE_URL", "gwi_chatbot.db"),
+		DatabaseDriver: getEnv("DATABASE_DRIVER", "sqlite3"),
+		HTTPPort:       getEnv("HTTP_PORT", "8080"),
+		LogLevel:       getEnv("LOG_LEVEL", "INFO"),
+		JWTSecret:      getEnv("JWT_SECRET", ""),
 
-	if AppConfig.GeminiAPIKey == "" {
-		log.Fatal("GEMINI_API_KEY environment variable is required")
+		AllowPublicSignup: getEnvAsBool("ALLOW_PUBLIC_SIGNUP", true),
+
+		LLMProvider:       getEnv("LLM_PROVIDER", "gemini"),
+		LLMModel:          getEnv("LLM_MODEL", ""),
+		EmbeddingProvider: getEnv("EMBEDDING_PROVIDER", ""),
+		EmbeddingModel:    getEnv("EMBEDDING_MODEL", ""),
+
+		OpenAIAPIKey:    getEnv("OPENAI_API_KEY", ""),
+		OpenAIBaseURL:   getEnv("OPENAI_BASE_URL", ""),
+		AnthropicAPIKey: getEnv("ANTHROPIC_API_KEY", ""),
+		OllamaBaseURL:   getEnv("OLLAMA_BASE_URL", ""),
+
+		EnabledTools: getEnv("ENABLED_TOOLS", ""),
+
+		MessageSchedulerIntervalSeconds: getEnvAsInt("MESSAGE_SCHEDULER_INTERVAL_SECONDS", 10),
+
+		MetricsToken: getEnv("METRICS_TOKEN", ""),
 	}
-	
+
 	if AppConfig.JWTSecret == "" {
 		log.Fatal("JWT_SECRET environment variable is required")
 	}
+
+	requireProviderCredentials(AppConfig.LLMProvider)
+	if AppConfig.EmbeddingProvider != "" {
+		requireProviderCredentials(AppConfig.EmbeddingProvider)
+	}
+}
+
+// requireProviderCredentials fails fast at startup if the credentials needed
+// by the named backend are missing, rather than letting every request to
+// that backend fail individually.
+func requireProviderCredentials(provider string) {
+	switch strings.ToLower(provider) {
+	case "", "gemini":
+		if AppConfig.GeminiAPIKey == "" {
+			log.Fatal("GEMINI_API_KEY environment variable is required when using the gemini provider")
+		}
+	case "openai":
+		if AppConfig.OpenAIAPIKey == "" {
+			log.Fatal("OPENAI_API_KEY environment variable is required when using the openai provider")
+		}
+	case "anthropic":
+		if AppConfig.AnthropicAPIKey == "" {
+			log.Fatal("ANTHROPIC_API_KEY environment variable is required when using the anthropic provider")
+		}
+	case "ollama":
+		// Ollama runs unauthenticated locally by default; OLLAMA_BASE_URL
+		// simply falls back to http://localhost:11434 if unset.
+	default:
+		log.Fatalf("Unknown provider %q (expected gemini, openai, anthropic, or ollama)", provider)
+	}
+}
+
+// ToolEnabled reports whether the named agent tool should be advertised to
+// the model. An empty EnabledTools enables every tool.
+func (c Config) ToolEnabled(name string) bool {
+	if c.EnabledTools == "" {
+		return true
+	}
+	for _, enabled := range strings.Split(c.EnabledTools, ",") {
+		if strings.TrimSpace(enabled) == name {
+			return true
+		}
+	}
+	return false
 }
 
 func getEnv(key string, defaultValue string) string {
@@ -55,3 +151,11 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
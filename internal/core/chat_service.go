@@ -1,32 +1,219 @@
 package core
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
+	"gwi.com/jedi-team-challenge/internal/auth"
+	"gwi.com/jedi-team-challenge/internal/core/errs"
+	"gwi.com/jedi-team-challenge/internal/core/hub"
+	"gwi.com/jedi-team-challenge/internal/metrics"
 	"gwi.com/jedi-team-challenge/internal/store"
 )
 
+// promptStarterCacheTTL controls how long a set of generated prompt starters
+// is reused for a given sample of data chunks before being regenerated.
+const promptStarterCacheTTL = 10 * time.Minute
+
+type promptStarterCacheEntry struct {
+	starters  []string
+	expiresAt time.Time
+}
+
 type ChatService struct {
-	dbStore    *store.SQLiteStore
+	dbStore    store.Store
 	ragService *RAGService
-	llmService *LLMService // For title generation
+	llmService LLMProvider // For title generation
+	hub        hub.Hub     // Live chat updates, fanned out to every connected device
+	metrics    *metrics.Registry
+
+	promptStarterMu    sync.Mutex
+	promptStarterCache map[string]promptStarterCacheEntry
 }
 
-func NewChatService(db *store.SQLiteStore, rag *RAGService, llm *LLMService) *ChatService {
+func NewChatService(db store.Store, rag *RAGService, llm LLMProvider, h hub.Hub, reg *metrics.Registry) *ChatService {
 	return &ChatService{
-		dbStore:    db,
-		ragService: rag,
-		llmService: llm,
+		dbStore:            db,
+		ragService:         rag,
+		llmService:         llm,
+		hub:                h,
+		metrics:            reg,
+		promptStarterCache: make(map[string]promptStarterCacheEntry),
 	}
 }
 
+// createMessage persists msg and records it against chat_messages_total,
+// keyed by sender, so every insertion point (user turns, model replies,
+// scheduled/edited messages) is counted the same way. RAGService's
+// persistToolInvocations uses the same persistMessage helper for tool
+// messages, so every message-creation site shares one chokepoint.
+func (s *ChatService) createMessage(msg *store.Message) error {
+	return persistMessage(s.dbStore, s.metrics, msg)
+}
+
+// persistMessage stores msg and records it against chat_messages_total,
+// keyed by sender. Shared by ChatService.createMessage and
+// RAGService.persistToolInvocations so every message-creation site in the
+// package stays in sync with whatever bookkeeping this does.
+func persistMessage(db store.Store, reg *metrics.Registry, msg *store.Message) error {
+	if err := db.CreateMessage(msg); err != nil {
+		return err
+	}
+	reg.IncChatMessage(msg.Sender)
+	return nil
+}
+
+// ChatEvent is the JSON envelope ChatService publishes to its hub for every
+// live update to a chat - a new message, a streamed token, a feedback
+// change, or a generated title - so every device connected to that chat via
+// ChatWebSocketHandler forwards the same bytes without re-deriving them.
+type ChatEvent struct {
+	Type      string      `json:"type"` // "message.created", "message.token", "context", "feedback.updated", "title_updated", or "error"
+	MessageID string      `json:"message_id,omitempty"`
+	Sender    string      `json:"sender,omitempty"`
+	Content   string      `json:"content,omitempty"`
+	Context   string      `json:"context,omitempty"`
+	Delta     string      `json:"delta,omitempty"`
+	Partial   bool        `json:"partial,omitempty"`
+	Negative  bool        `json:"negative,omitempty"`
+	Title     string      `json:"title,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Usage     *TokenUsage `json:"usage,omitempty"`
+}
+
+// publishChatEvent marshals event and publishes it to chatID's hub topic. A
+// marshalling failure is logged and swallowed rather than returned, since
+// callers are mid-way through an otherwise-successful write and shouldn't
+// fail the request over a best-effort live update.
+func (s *ChatService) publishChatEvent(chatID string, event ChatEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal chat event %q for chat %s: %v", event.Type, chatID, err)
+		return
+	}
+	if err := s.hub.Publish(chatID, data); err != nil {
+		log.Printf("Failed to publish chat event %q for chat %s: %v", event.Type, chatID, err)
+	}
+}
+
+// SubscribeChatEvents registers a listener for every live event published
+// for chatID. The returned channel is closed, and the subscription removed,
+// once the returned unsubscribe function is called. ChatWebSocketHandler
+// subscribes here for the lifetime of each connection.
+func (s *ChatService) SubscribeChatEvents(chatID string) (<-chan []byte, func()) {
+	return s.hub.Subscribe(chatID)
+}
+
 // GetOrCreateUser ensures a user exists and returns their internal ID.
 func (s *ChatService) GetOrCreateUser(externalUserID string) (*store.User, error) {
 	return s.dbStore.GetOrCreateUser(externalUserID)
 }
 
+// GetUserByExternalID looks up a user by their external (login) ID, for
+// SignupHandler/LoginHandler/JWTAuthMiddleware.
+func (s *ChatService) GetUserByExternalID(externalUserID string) (*store.User, error) {
+	return s.dbStore.GetUserByExternalID(externalUserID)
+}
+
+// CreateUser registers a new account with an already-hashed password. The
+// very first account ever created is promoted to store.RoleAdmin.
+func (s *ChatService) CreateUser(externalUserID, passwordHash string) (*store.User, error) {
+	return s.dbStore.CreateUser(externalUserID, passwordHash)
+}
+
+// GetUsers lists up to limit users, newest first, for the admin user list.
+func (s *ChatService) GetUsers(limit int, cursor string) ([]store.User, string, error) {
+	return s.dbStore.GetUsers(limit, cursor)
+}
+
+// SetUserDisabled enables or disables a user's account. A disabled user is
+// rejected by JWTAuthMiddleware even with an otherwise valid token.
+func (s *ChatService) SetUserDisabled(userID int64, disabled bool) error {
+	return s.dbStore.SetUserDisabled(userID, disabled)
+}
+
+// ResetUserPassword hashes newPassword and overwrites userID's stored hash,
+// for an admin-initiated password reset.
+func (s *ChatService) ResetUserPassword(userID int64, newPassword string) error {
+	hash, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.dbStore.SetUserPasswordHash(userID, hash)
+}
+
+// ForceLogoutUser bumps userID's TokenVersion, invalidating every JWT issued
+// to them before this call, and revokes all of their sessions so none of
+// their refresh tokens can mint a new one.
+func (s *ChatService) ForceLogoutUser(userID int64) error {
+	if err := s.dbStore.BumpUserTokenVersion(userID); err != nil {
+		return err
+	}
+	return s.dbStore.RevokeAllSessionsForUser(userID)
+}
+
+// DeleteUserChat deletes any chat by ID regardless of its owner, for admin
+// moderation. Unlike the user-facing delete paths, there is no ownership
+// check here.
+func (s *ChatService) DeleteUserChat(chatID string) error {
+	if err := s.dbStore.DeleteChat(chatID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return errs.NewNotFound("chat_not_found", "chat not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// GetUserByID looks up a user by internal ID, for RefreshHandler to re-fetch
+// the user a session belongs to.
+func (s *ChatService) GetUserByID(userID int64) (*store.User, error) {
+	return s.dbStore.GetUserByID(userID)
+}
+
+// CreateSession records a freshly issued refresh token's session row, for
+// LoginHandler.
+func (s *ChatService) CreateSession(userID int64, refreshTokenHash, userAgent, ip string, expiresAt time.Time) (*store.Session, error) {
+	return s.dbStore.CreateSession(userID, refreshTokenHash, userAgent, ip, expiresAt)
+}
+
+// GetSessionByID looks up a session by ID, for RefreshHandler/LogoutHandler
+// and JWTAuthMiddleware's revocation check.
+func (s *ChatService) GetSessionByID(sessionID string) (*store.Session, error) {
+	return s.dbStore.GetSessionByID(sessionID)
+}
+
+// RotateSessionRefreshToken overwrites a session's refresh token hash and
+// expiry, for RefreshHandler's refresh-token rotation.
+func (s *ChatService) RotateSessionRefreshToken(sessionID, refreshTokenHash string, expiresAt time.Time) error {
+	return s.dbStore.RotateSessionRefreshToken(sessionID, refreshTokenHash, expiresAt)
+}
+
+// RevokeSession revokes a single session, for LogoutHandler and
+// DeleteSessionHandler.
+func (s *ChatService) RevokeSession(sessionID string) error {
+	return s.dbStore.RevokeSession(sessionID)
+}
+
+// RevokeAllUserSessions revokes every session belonging to userID, for
+// LogoutAllHandler.
+func (s *ChatService) RevokeAllUserSessions(userID int64) error {
+	return s.dbStore.RevokeAllSessionsForUser(userID)
+}
+
+// GetUserSessions lists every session belonging to userID, newest first, for
+// GetSessionsHandler.
+func (s *ChatService) GetUserSessions(userID int64) ([]store.Session, error) {
+	return s.dbStore.GetSessionsByUserID(userID)
+}
+
 func (s *ChatService) CreateChat(userID int64, firstMessageContent *string) (*store.Chat, []store.Message, error) {
 	// This should ideally be wrapped in a transaction
 
@@ -36,14 +223,16 @@ func (s *ChatService) CreateChat(userID int64, firstMessageContent *string) (*st
 	}
 
 	var messages []store.Message
+	branchID := derefOrEmpty(chat.CurrentBranchID)
 
 	if firstMessageContent != nil && *firstMessageContent != "" {
 		userMsg := store.Message{
-			ChatID:  chat.ID,
-			Sender:  "user",
-			Content: *firstMessageContent,
+			ChatID:   chat.ID,
+			Sender:   "user",
+			Content:  *firstMessageContent,
+			BranchID: branchID,
 		}
-		if err := s.dbStore.CreateMessage(&userMsg); err != nil {
+		if err := s.createMessage(&userMsg); err != nil {
 			// Potentially rollback chat creation or log warning
 			log.Printf("Failed to store first user message for new chat %s: %v", chat.ID, err)
 			// Continue, but the chat will be empty initially
@@ -51,19 +240,22 @@ func (s *ChatService) CreateChat(userID int64, firstMessageContent *string) (*st
 			messages = append(messages, userMsg)
 
 			// Generate model response for the first message
-			modelContent, err := s.ragService.GenerateResponse(chat.ID, userID, userMsg.Content)
+			modelContent, modelParentID, _, err := s.ragService.GenerateResponse(chat.ID, userID, branchID, userMsg.ID, userMsg.Content)
 			if err != nil {
 				// Log error, but still return the chat and user message
 				log.Printf("Failed to generate initial model response for chat %s: %v", chat.ID, err)
 				modelContent = "I encountered an issue trying to respond. Please try again."
+				modelParentID = userMsg.ID
 			}
 
 			modelMsg := store.Message{
-				ChatID:  chat.ID,
-				Sender:  "model",
-				Content: modelContent,
+				ChatID:          chat.ID,
+				Sender:          "model",
+				Content:         modelContent,
+				ParentMessageID: &modelParentID,
+				BranchID:        branchID,
 			}
-			if err := s.dbStore.CreateMessage(&modelMsg); err != nil {
+			if err := s.createMessage(&modelMsg); err != nil {
 				log.Printf("Failed to store initial model message for new chat %s: %v", chat.ID, err)
 			} else {
 				messages = append(messages, modelMsg)
@@ -77,65 +269,142 @@ func (s *ChatService) CreateChat(userID int64, firstMessageContent *string) (*st
 	return chat, messages, nil
 }
 
-func (s *ChatService) GetChats(userID int64) ([]store.Chat, error) {
-	return s.dbStore.GetChatsByUserID(userID)
+// GetChats returns a cursor-paginated page of userID's chats, newest first.
+// cursor is the value returned as nextCursor by a previous call, or "" for
+// the first page.
+func (s *ChatService) GetChats(userID int64, limit int, cursor string) ([]store.Chat, string, error) {
+	return s.dbStore.GetChatsByUserID(userID, limit, cursor)
 }
 
-func (s *ChatService) GetChatDetails(chatID string, userID int64) (*store.Chat, []store.Message, error) {
+// GetChatDetails returns a chat along with a cursor-paginated page of the
+// transcript of one branch (branchID, or the chat's current branch if empty)
+// and a lightweight index of every branch in the conversation tree, so a UI
+// can render a switcher. Pagination walks backward from the newest message;
+// cursor is the value returned as nextCursor by a previous call, or "" for
+// the first (most recent) page.
+func (s *ChatService) GetChatDetails(chatID string, userID int64, branchID string, limit int, cursor string) (*store.Chat, []store.Message, string, []store.Branch, error) {
 	chat, err := s.dbStore.GetChatByID(chatID, userID)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get chat: %w", err)
+		return nil, nil, "", nil, fmt.Errorf("failed to get chat: %w", err)
 	}
 	if chat == nil {
-		return nil, nil, nil // Not found
+		return nil, nil, "", nil, nil // Not found
+	}
+
+	if branchID == "" {
+		branchID = derefOrEmpty(chat.CurrentBranchID)
 	}
 
-	messages, err := s.dbStore.GetMessagesByChatID(chatID, 100, 0) // Get up to 100 messages
+	allMessages, err := s.dbStore.GetMessagesByBranch(chatID, branchID)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get messages for chat: %w", err)
+		return nil, nil, "", nil, fmt.Errorf("failed to get messages for branch: %w", err)
 	}
-	return chat, messages, nil
+
+	messages, nextCursor, err := paginateMessages(allMessages, limit, cursor)
+	if err != nil {
+		return nil, nil, "", nil, err
+	}
+
+	branches, err := s.dbStore.GetBranches(chatID)
+	if err != nil {
+		return nil, nil, "", nil, fmt.Errorf("failed to get branches for chat: %w", err)
+	}
+
+	return chat, messages, nextCursor, branches, nil
+}
+
+// paginateMessages pages backward through all, which GetMessagesByBranch
+// returns oldest-first, returning up to limit messages older than cursor (or
+// the most recent limit messages if cursor is ""). The returned page stays
+// in oldest-first order, matching the order callers already expect for a
+// transcript. Branch transcripts are reconstructed in memory rather than
+// queried with SQL LIMIT/OFFSET (they're threaded via ParentMessageID, not a
+// flat chat_id scan), so pagination is applied here instead of in the store.
+func paginateMessages(all []store.Message, limit int, cursor string) ([]store.Message, string, error) {
+	cursorTime, cursorID, err := store.DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	end := len(all)
+	if cursor != "" {
+		end = 0
+		for i, m := range all {
+			if m.Timestamp.Before(cursorTime) || (m.Timestamp.Equal(cursorTime) && m.ID < cursorID) {
+				end = i + 1
+			}
+		}
+	}
+
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+
+	nextCursor := ""
+	if start > 0 {
+		oldest := all[start]
+		nextCursor = store.EncodeCursor(oldest.Timestamp, oldest.ID)
+	}
+	return all[start:end], nextCursor, nil
 }
 
-func (s *ChatService) PostMessage(chatID string, userID int64, userContent string) (*store.Message, error) {
+// PostMessage stores userContent, generates a model reply via the RAG
+// service, and returns the stored model message along with the token usage
+// the LLM call reported (nil if the provider didn't report any).
+func (s *ChatService) PostMessage(chatID string, userID int64, userContent string) (*store.Message, *TokenUsage, error) {
 	// This should ideally be wrapped in a transaction
 
 	// Verify chat exists and belongs to user
 	chat, err := s.dbStore.GetChatByID(chatID, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify chat: %w", err)
+		return nil, nil, fmt.Errorf("failed to verify chat: %w", err)
 	}
 	if chat == nil {
-		return nil, fmt.Errorf("chat not found")
+		return nil, nil, errs.NewNotFound("chat_not_found", "chat not found")
+	}
+	branchID := derefOrEmpty(chat.CurrentBranchID)
+
+	// Thread this message off the current tip of the active branch.
+	parentMessageID, err := s.currentBranchTipID(chatID, branchID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve branch tip: %w", err)
 	}
 
 	// Store user message
 	userMsg := store.Message{
-		ChatID:  chatID,
-		Sender:  "user",
-		Content: userContent,
+		ChatID:          chatID,
+		Sender:          "user",
+		Content:         userContent,
+		ParentMessageID: parentMessageID,
+		BranchID:        branchID,
 	}
-	if err := s.dbStore.CreateMessage(&userMsg); err != nil {
-		return nil, fmt.Errorf("failed to store user message: %w", err)
+	if err := s.createMessage(&userMsg); err != nil {
+		return nil, nil, fmt.Errorf("failed to store user message: %w", err)
 	}
+	s.publishChatEvent(chatID, ChatEvent{Type: "message.created", MessageID: userMsg.ID, Sender: "user", Content: userMsg.Content})
 
 	// Generate model response using RAG service
-	modelContent, err := s.ragService.GenerateResponse(chatID, userID, userContent)
+	modelContent, modelParentID, usage, err := s.ragService.GenerateResponse(chatID, userID, branchID, userMsg.ID, userContent)
 	if err != nil {
 		// Log error, maybe return a canned error message to user
 		log.Printf("Error generating model response for chat %s: %v", chatID, err)
 		modelContent = "I'm sorry, I encountered an error while processing your request."
+		modelParentID = userMsg.ID
 	}
 
 	// Store model message
 	modelMessage := store.Message{
-		ChatID:  chatID,
-		Sender:  "model",
-		Content: modelContent,
+		ChatID:          chatID,
+		Sender:          "model",
+		Content:         modelContent,
+		ParentMessageID: &modelParentID,
+		BranchID:        branchID,
 	}
-	if err := s.dbStore.CreateMessage(&modelMessage); err != nil {
-		return nil, fmt.Errorf("failed to store model message: %w", err)
+	if err := s.createMessage(&modelMessage); err != nil {
+		return nil, nil, fmt.Errorf("failed to store model message: %w", err)
 	}
+	s.publishChatEvent(chatID, ChatEvent{Type: "message.created", MessageID: modelMessage.ID, Sender: "model", Content: modelMessage.Content, Usage: usage})
 
 	// If chat doesn't have a title yet (e.g. created without a first message, or title generation failed)
 	// attempt to generate it now.
@@ -156,7 +425,141 @@ func (s *ChatService) PostMessage(chatID string, userID int64, userContent strin
 		}
 	}
 
-	return &modelMessage, nil
+	return &modelMessage, usage, nil
+}
+
+// StreamEvent is emitted while a streamed reply is being assembled via
+// PostMessageStream. Type is one of "context", "token", "done", or "error";
+// the other fields are populated according to Type. A "done" event carries
+// MessageID, and has Partial set if ctx was cancelled before the model
+// finished generating (the message is still persisted, with whatever content
+// had been assembled so far).
+type StreamEvent struct {
+	Type      string
+	Context   string
+	Token     string
+	MessageID string
+	Partial   bool
+	Err       error
+	// Usage is set on the "done" event when the provider reported token
+	// counts for the completion; nil otherwise.
+	Usage *TokenUsage
+}
+
+// PostMessageStream is an alternative to PostMessage for callers that want to
+// forward the model's reply to a client as it is generated (e.g. over SSE).
+// The user message is stored immediately; the assistant's message is
+// persisted once the stream ends, either complete or, if ctx was cancelled
+// mid-generation, as a partial message carrying whatever was assembled so
+// far. Cancelling ctx aborts the upstream LLM call.
+func (s *ChatService) PostMessageStream(ctx context.Context, chatID string, userID int64, userContent string) (<-chan StreamEvent, error) {
+	chat, err := s.dbStore.GetChatByID(chatID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify chat: %w", err)
+	}
+	if chat == nil {
+		return nil, errs.NewNotFound("chat_not_found", "chat not found")
+	}
+	branchID := derefOrEmpty(chat.CurrentBranchID)
+
+	parentMessageID, err := s.currentBranchTipID(chatID, branchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve branch tip: %w", err)
+	}
+
+	userMsg := store.Message{
+		ChatID:          chatID,
+		Sender:          "user",
+		Content:         userContent,
+		ParentMessageID: parentMessageID,
+		BranchID:        branchID,
+	}
+	if err := s.createMessage(&userMsg); err != nil {
+		return nil, fmt.Errorf("failed to store user message: %w", err)
+	}
+	s.publishChatEvent(chatID, ChatEvent{Type: "message.created", MessageID: userMsg.ID, Sender: "user", Content: userMsg.Content})
+
+	genEvents, err := s.ragService.GenerateResponseStream(ctx, chatID, userID, branchID, userContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start model response stream: %w", err)
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+
+		var content strings.Builder
+		var terminal bool // saw an explicit "done" or "error" event, as opposed to ctx cancellation
+		var usage *TokenUsage
+		for genEvent := range genEvents {
+			switch genEvent.Type {
+			case "context":
+				out <- StreamEvent{Type: "context", Context: genEvent.Context}
+			case "token":
+				content.WriteString(genEvent.Token)
+				out <- StreamEvent{Type: "token", Token: genEvent.Token}
+			case "error":
+				terminal = true
+				out <- StreamEvent{Type: "error", Err: fmt.Errorf("model stream failed: %w", genEvent.Err)}
+				return
+			case "done":
+				terminal = true
+				usage = genEvent.Usage
+			}
+		}
+
+		partial := !terminal
+		if partial && content.Len() == 0 {
+			// Cancelled before any tokens arrived; nothing worth persisting.
+			return
+		}
+
+		modelMessage := store.Message{
+			ChatID:          chatID,
+			Sender:          "model",
+			Content:         content.String(),
+			ParentMessageID: &userMsg.ID,
+			BranchID:        branchID,
+			Partial:         partial,
+		}
+		if err := s.createMessage(&modelMessage); err != nil {
+			out <- StreamEvent{Type: "error", Err: fmt.Errorf("failed to store model message: %w", err)}
+			return
+		}
+		out <- StreamEvent{Type: "done", MessageID: modelMessage.ID, Partial: partial, Usage: usage}
+
+		if !partial && (chat.Title == nil || *chat.Title == "") {
+			go s.generateAndSaveChatTitle(chatID, userID, userContent)
+		}
+	}()
+
+	return out, nil
+}
+
+// StreamMessageToChat posts userContent to chatID the same way
+// PostMessageStream does, but publishes every event to the chat's hub topic
+// instead of returning a channel, so every device connected to the chat -
+// not just the one that sent the message - sees the reply as it's
+// generated. ChatWebSocketHandler uses this instead of PostMessageStream.
+func (s *ChatService) StreamMessageToChat(ctx context.Context, chatID string, userID int64, userContent string) error {
+	events, err := s.PostMessageStream(ctx, chatID, userID, userContent)
+	if err != nil {
+		return err
+	}
+
+	for event := range events {
+		switch event.Type {
+		case "context":
+			s.publishChatEvent(chatID, ChatEvent{Type: "context", Context: event.Context})
+		case "token":
+			s.publishChatEvent(chatID, ChatEvent{Type: "message.token", Delta: event.Token})
+		case "error":
+			s.publishChatEvent(chatID, ChatEvent{Type: "error", Error: event.Err.Error()})
+		case "done":
+			s.publishChatEvent(chatID, ChatEvent{Type: "message.created", MessageID: event.MessageID, Sender: "model", Partial: event.Partial, Usage: event.Usage})
+		}
+	}
+	return nil
 }
 
 func (s *ChatService) generateAndSaveChatTitle(chatID string, userID int64, basisContent string) {
@@ -171,12 +574,253 @@ func (s *ChatService) generateAndSaveChatTitle(chatID string, userID int64, basi
 	err = s.dbStore.UpdateChatTitle(chatID, userID, title)
 	if err != nil {
 		log.Printf("Failed to save generated title '%s' for chat %s: %v", title, chatID, err)
-	} else {
-		log.Printf("Successfully generated and saved title '%s' for chat %s", title, chatID)
+		return
 	}
+	log.Printf("Successfully generated and saved title '%s' for chat %s", title, chatID)
+	s.publishChatEvent(chatID, ChatEvent{Type: "title_updated", Title: title})
 }
 
 func (s *ChatService) SetMessageFeedback(messageID string, userID int64, negative bool) error {
 	// Should verify that the message belongs to the user's chat
-	return s.dbStore.UpdateMessageFeedback(messageID, negative)
+	if err := s.dbStore.UpdateMessageFeedback(messageID, negative); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return errs.NewNotFound("message_not_found", "message not found")
+		}
+		return err
+	}
+
+	if msg, err := s.dbStore.GetMessageByID(messageID); err == nil && msg != nil {
+		s.publishChatEvent(msg.ChatID, ChatEvent{Type: "feedback.updated", MessageID: messageID, Negative: negative})
+	}
+	return nil
+}
+
+// DeleteMessage soft-deletes messageID. The row is kept (so the conversation
+// tree and any later replies stay intact) but its content is tombstoned on
+// every subsequent read, including the history RAGService assembles for the
+// model.
+func (s *ChatService) DeleteMessage(messageID string, userID int64) error {
+	if err := s.dbStore.DeleteMessage(messageID, userID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return errs.NewNotFound("message_not_found", "message not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// ScheduleMessage queues userContent for delivery at deliverAt instead of
+// posting it immediately. MessageScheduler threads it onto the chat's
+// current branch and generates the model's reply once deliverAt arrives, so
+// the branch it lands on is whichever is current then, not now.
+func (s *ChatService) ScheduleMessage(chatID string, userID int64, userContent string, deliverAt time.Time) (*store.Message, error) {
+	chat, err := s.dbStore.GetChatByID(chatID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify chat: %w", err)
+	}
+	if chat == nil {
+		return nil, errs.NewNotFound("chat_not_found", "chat not found")
+	}
+
+	msg := store.Message{
+		ChatID:  chatID,
+		Sender:  "user",
+		Content: userContent,
+	}
+	if err := s.dbStore.CreateScheduledMessage(&msg, deliverAt); err != nil {
+		return nil, fmt.Errorf("failed to store scheduled message: %w", err)
+	}
+	return &msg, nil
+}
+
+// CancelScheduledMessage withdraws a message queued with ScheduleMessage
+// before it's delivered. It's scoped to chats owned by userID, and is a no-op
+// error once MessageScheduler has already promoted the message.
+func (s *ChatService) CancelScheduledMessage(messageID string, userID int64) error {
+	if err := s.dbStore.CancelScheduledMessage(messageID, userID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return errs.NewNotFound("scheduled_message_not_found", "scheduled message not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// DeliverScheduledMessage promotes a due scheduled message into a live one:
+// it threads the message onto the current tip of userID's chat, generates
+// the model's reply the same way PostMessage does, and stores both.
+// MessageScheduler calls this once a message's ScheduledAt has passed.
+func (s *ChatService) DeliverScheduledMessage(msg store.Message, userID int64) error {
+	chat, err := s.dbStore.GetChatByID(msg.ChatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to verify chat: %w", err)
+	}
+	if chat == nil {
+		return errs.NewNotFound("chat_not_found", "chat not found")
+	}
+	branchID := derefOrEmpty(chat.CurrentBranchID)
+
+	parentMessageID, err := s.currentBranchTipID(msg.ChatID, branchID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve branch tip: %w", err)
+	}
+
+	if err := s.dbStore.PromoteScheduledMessage(msg.ID, parentMessageID, branchID); err != nil {
+		return fmt.Errorf("failed to promote scheduled message: %w", err)
+	}
+
+	modelContent, modelParentID, _, err := s.ragService.GenerateResponse(msg.ChatID, userID, branchID, msg.ID, msg.Content)
+	if err != nil {
+		log.Printf("Error generating model response for scheduled message %s: %v", msg.ID, err)
+		modelContent = "I'm sorry, I encountered an error while processing your request."
+		modelParentID = msg.ID
+	}
+
+	modelMessage := store.Message{
+		ChatID:          msg.ChatID,
+		Sender:          "model",
+		Content:         modelContent,
+		ParentMessageID: &modelParentID,
+		BranchID:        branchID,
+	}
+	if err := s.createMessage(&modelMessage); err != nil {
+		return fmt.Errorf("failed to store model message: %w", err)
+	}
+	s.publishChatEvent(msg.ChatID, ChatEvent{Type: "message.created", MessageID: modelMessage.ID, Sender: "model", Content: modelMessage.Content})
+
+	if chat.Title == nil || *chat.Title == "" {
+		go s.generateAndSaveChatTitle(msg.ChatID, userID, msg.Content)
+	}
+	return nil
+}
+
+// EditMessage replaces a prior user message by creating a sibling under a
+// new branch (rather than mutating the original), regenerates the assistant
+// reply against it, and makes the new branch the chat's current one.
+func (s *ChatService) EditMessage(messageID string, userID int64, newContent string) (*store.Message, error) {
+	original, err := s.dbStore.GetMessageByID(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up message: %w", err)
+	}
+	if original == nil {
+		return nil, errs.NewNotFound("message_not_found", "message not found")
+	}
+	if original.Sender != "user" {
+		return nil, errs.NewValidation("message_not_editable", "only user messages can be edited")
+	}
+
+	chat, err := s.dbStore.GetChatByID(original.ChatID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify chat: %w", err)
+	}
+	if chat == nil {
+		return nil, errs.NewNotFound("chat_not_found", "chat not found")
+	}
+
+	newBranchID := uuid.NewString()
+	editedMsg := store.Message{
+		ChatID:          original.ChatID,
+		Sender:          "user",
+		Content:         newContent,
+		ParentMessageID: original.ParentMessageID,
+		BranchID:        newBranchID,
+	}
+	if err := s.createMessage(&editedMsg); err != nil {
+		return nil, fmt.Errorf("failed to store edited message: %w", err)
+	}
+	s.publishChatEvent(original.ChatID, ChatEvent{Type: "message.created", MessageID: editedMsg.ID, Sender: "user", Content: editedMsg.Content})
+
+	if err := s.dbStore.UpdateChatCurrentBranch(original.ChatID, userID, newBranchID); err != nil {
+		return nil, fmt.Errorf("failed to switch chat to new branch: %w", err)
+	}
+
+	modelContent, modelParentID, _, err := s.ragService.GenerateResponse(original.ChatID, userID, newBranchID, editedMsg.ID, newContent)
+	if err != nil {
+		log.Printf("Error generating model response for edited message %s: %v", messageID, err)
+		modelContent = "I'm sorry, I encountered an error while processing your request."
+		modelParentID = editedMsg.ID
+	}
+
+	modelMessage := store.Message{
+		ChatID:          original.ChatID,
+		Sender:          "model",
+		Content:         modelContent,
+		ParentMessageID: &modelParentID,
+		BranchID:        newBranchID,
+	}
+	if err := s.createMessage(&modelMessage); err != nil {
+		return nil, fmt.Errorf("failed to store model message: %w", err)
+	}
+	s.publishChatEvent(original.ChatID, ChatEvent{Type: "message.created", MessageID: modelMessage.ID, Sender: "model", Content: modelMessage.Content})
+
+	return &modelMessage, nil
+}
+
+// currentBranchTipID returns the ID of the most recent message in branchID,
+// or nil if the branch doesn't have one yet, for threading the next message
+// created in it.
+func (s *ChatService) currentBranchTipID(chatID, branchID string) (*string, error) {
+	tip, err := s.dbStore.GetLastNMessagesByBranch(chatID, branchID, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(tip) == 0 {
+		return nil, nil
+	}
+	id := tip[0].ID
+	return &id, nil
+}
+
+// derefOrEmpty returns *s, or "" if s is nil.
+func derefOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// GetPromptStarters samples limit data chunks from the ingested corpus and
+// asks the LLM to turn them into suggested opening questions, so the
+// frontend can offer a "try asking..." panel without hardcoding questions.
+// Results are cached in-memory, keyed by limit, for promptStarterCacheTTL to
+// avoid a model call on every page load. The cache key is the requested
+// limit rather than the sampled chunks themselves: GetRandomDataChunks
+// samples uniformly at random, so keying by chunk would pick a fresh cache
+// key - and miss - on nearly every call.
+func (s *ChatService) GetPromptStarters(limit int) ([]string, error) {
+	cacheKey := strconv.Itoa(limit)
+
+	s.promptStarterMu.Lock()
+	if entry, ok := s.promptStarterCache[cacheKey]; ok && time.Now().Before(entry.expiresAt) {
+		s.promptStarterMu.Unlock()
+		return entry.starters, nil
+	}
+	s.promptStarterMu.Unlock()
+
+	chunks, err := s.dbStore.GetRandomDataChunks(limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample data chunks for prompt starters: %w", err)
+	}
+	if len(chunks) == 0 {
+		return []string{}, nil
+	}
+
+	contents := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		contents[i] = chunk.Content
+	}
+
+	starters, err := s.llmService.GeneratePromptStarters(contents, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate prompt starters: %w", err)
+	}
+
+	s.promptStarterMu.Lock()
+	s.promptStarterCache[cacheKey] = promptStarterCacheEntry{
+		starters:  starters,
+		expiresAt: time.Now().Add(promptStarterCacheTTL),
+	}
+	s.promptStarterMu.Unlock()
+
+	return starters, nil
 }
@@ -0,0 +1,79 @@
+// Package errs defines the typed error taxonomy ChatService methods return,
+// so internal/api can map a failure to the right HTTP status and a stable
+// machine-readable code via errors.As instead of comparing error strings.
+package errs
+
+import "fmt"
+
+// NotFound indicates the requested resource doesn't exist, or (for
+// ownership-scoped lookups, e.g. a chat belonging to another user) exists but
+// isn't visible to the caller. Collapsing "doesn't exist" and "not yours"
+// into one error avoids leaking which case applies to an unauthorized caller.
+type NotFound struct {
+	Code    string
+	Message string
+	Cause   error
+}
+
+func (e *NotFound) Error() string { return errorString(e.Message, e.Cause) }
+func (e *NotFound) Unwrap() error { return e.Cause }
+
+// NewNotFound returns a NotFound error with no underlying cause.
+func NewNotFound(code, message string) error {
+	return &NotFound{Code: code, Message: message}
+}
+
+// Forbidden indicates the caller is authenticated but not allowed to perform
+// the requested action.
+type Forbidden struct {
+	Code    string
+	Message string
+	Cause   error
+}
+
+func (e *Forbidden) Error() string { return errorString(e.Message, e.Cause) }
+func (e *Forbidden) Unwrap() error { return e.Cause }
+
+// NewForbidden returns a Forbidden error with no underlying cause.
+func NewForbidden(code, message string) error {
+	return &Forbidden{Code: code, Message: message}
+}
+
+// Conflict indicates the request can't be completed because of the target
+// resource's current state.
+type Conflict struct {
+	Code    string
+	Message string
+	Cause   error
+}
+
+func (e *Conflict) Error() string { return errorString(e.Message, e.Cause) }
+func (e *Conflict) Unwrap() error { return e.Cause }
+
+// NewConflict returns a Conflict error with no underlying cause.
+func NewConflict(code, message string) error {
+	return &Conflict{Code: code, Message: message}
+}
+
+// Validation indicates the request itself is invalid independent of any
+// stored state (e.g. editing a message that isn't from the user).
+type Validation struct {
+	Code    string
+	Message string
+	Cause   error
+}
+
+func (e *Validation) Error() string { return errorString(e.Message, e.Cause) }
+func (e *Validation) Unwrap() error { return e.Cause }
+
+// NewValidation returns a Validation error with no underlying cause.
+func NewValidation(code, message string) error {
+	return &Validation{Code: code, Message: message}
+}
+
+func errorString(message string, cause error) string {
+	if cause != nil {
+		return fmt.Sprintf("%s: %v", message, cause)
+	}
+	return message
+}
@@ -0,0 +1,22 @@
+// Package hub provides a per-topic publish/subscribe primitive backing live
+// chat updates across every device connected to the same chat. ChatService
+// publishes JSON-encoded events keyed by chat ID; ChatWebSocketHandler
+// subscribes to a chat's topic and forwards whatever arrives to its client.
+//
+// Hub is an interface so InMemoryHub (a single process's channel map) can
+// later be swapped for a Redis-backed implementation without any caller -
+// ChatService or the WebSocket handler - changing, once the service runs
+// behind more than one instance.
+package hub
+
+// Hub publishes byte-slice events to every subscriber of a topic (a chat
+// ID). A publish a subscriber can't keep up with is dropped for that
+// subscriber rather than blocking the publisher or other subscribers.
+type Hub interface {
+	// Subscribe registers a listener for topic. The returned channel is
+	// closed, and the subscription removed, once unsubscribe is called.
+	Subscribe(topic string) (events <-chan []byte, unsubscribe func())
+
+	// Publish delivers event to every current subscriber of topic.
+	Publish(topic string, event []byte) error
+}
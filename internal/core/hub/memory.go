@@ -0,0 +1,59 @@
+package hub
+
+import "sync"
+
+// subscriberBufferSize bounds how many not-yet-delivered events a slow
+// subscriber can accumulate before further publishes to it are dropped.
+const subscriberBufferSize = 16
+
+// InMemoryHub is a Hub backed by an in-process map of channels, good enough
+// for a single server instance. See the package doc for swapping in a
+// Redis-backed Hub to fan out across more than one.
+type InMemoryHub struct {
+	mu   sync.Mutex
+	subs map[string][]chan []byte
+}
+
+// NewInMemoryHub returns an empty InMemoryHub.
+func NewInMemoryHub() *InMemoryHub {
+	return &InMemoryHub{subs: make(map[string][]chan []byte)}
+}
+
+func (h *InMemoryHub) Subscribe(topic string) (<-chan []byte, func()) {
+	ch := make(chan []byte, subscriberBufferSize)
+
+	h.mu.Lock()
+	h.subs[topic] = append(h.subs[topic], ch)
+	h.mu.Unlock()
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		subs := h.subs[topic]
+		for i, sub := range subs {
+			if sub == ch {
+				h.subs[topic] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		if len(h.subs[topic]) == 0 {
+			delete(h.subs, topic)
+		}
+		close(ch)
+	}
+	return ch, unsubscribe
+}
+
+// Publish delivers event to every current subscriber of topic, dropping it
+// for any subscriber whose buffer is already full instead of blocking.
+func (h *InMemoryHub) Publish(topic string, event []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, ch := range h.subs[topic] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return nil
+}
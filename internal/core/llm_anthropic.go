@@ -0,0 +1,259 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gwi.com/jedi-team-challenge/internal/agent"
+	"gwi.com/jedi-team-challenge/internal/config"
+)
+
+const (
+	defaultAnthropicBaseURL       = "https://api.anthropic.com/v1"
+	defaultAnthropicChatModelName = "claude-3-5-haiku-latest"
+	anthropicAPIVersion           = "2023-06-01"
+	anthropicMaxTokens            = 1024
+)
+
+// AnthropicProvider is the LLMProvider backed by Anthropic's Messages API.
+// Anthropic does not expose an embeddings endpoint, so GetEmbedding always
+// fails; deployments using this provider must set EMBEDDING_PROVIDER to a
+// different backend.
+type AnthropicProvider struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	chatModel  string
+}
+
+func NewAnthropicProvider(chatModel string) *AnthropicProvider {
+	if chatModel == "" {
+		chatModel = defaultAnthropicChatModelName
+	}
+	return &AnthropicProvider{
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		baseURL:    defaultAnthropicBaseURL,
+		apiKey:     config.AppConfig.AnthropicAPIKey,
+		chatModel:  chatModel,
+	}
+}
+
+func (p *AnthropicProvider) Close() {}
+
+func (p *AnthropicProvider) GetEmbedding(text string) ([]float32, error) {
+	return nil, fmt.Errorf("anthropic provider does not support embeddings; set EMBEDDING_PROVIDER to a different backend")
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func toAnthropicMessages(promptHistory []ChatMessage) []anthropicMessage {
+	msgs := make([]anthropicMessage, 0, len(promptHistory))
+	for _, m := range promptHistory {
+		role := m.Role
+		if role == "model" {
+			role = "assistant"
+		}
+		msgs = append(msgs, anthropicMessage{Role: role, Content: m.Content})
+	}
+	return msgs
+}
+
+func (p *AnthropicProvider) newRequest(ctx context.Context, body []byte) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build anthropic request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
+	return httpReq, nil
+}
+
+func (p *AnthropicProvider) GetChatCompletion(promptHistory []ChatMessage) (string, *TokenUsage, error) {
+	if len(promptHistory) == 0 {
+		return "", nil, fmt.Errorf("prompt history is empty for chat completion")
+	}
+
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model":      p.chatModel,
+		"system":     chatSystemInstruction,
+		"messages":   toAnthropicMessages(promptHistory),
+		"max_tokens": anthropicMaxTokens,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal anthropic request: %w", err)
+	}
+
+	httpReq, err := p.newRequest(context.Background(), reqBody)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return "", nil, fmt.Errorf("anthropic messages request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("anthropic messages request returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", nil, fmt.Errorf("failed to decode anthropic response: %w", err)
+	}
+	usage := &TokenUsage{PromptTokens: result.Usage.InputTokens, CompletionTokens: result.Usage.OutputTokens}
+
+	var text strings.Builder
+	for _, block := range result.Content {
+		text.WriteString(block.Text)
+	}
+	if text.Len() == 0 {
+		return "I'm sorry, I couldn't generate a response at this time. Please try again.", usage, nil
+	}
+	return text.String(), usage, nil
+}
+
+func (p *AnthropicProvider) GetChatCompletionStream(ctx context.Context, promptHistory []ChatMessage) (<-chan StreamChunk, error) {
+	if len(promptHistory) == 0 {
+		return nil, fmt.Errorf("prompt history is empty for chat completion")
+	}
+
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model":      p.chatModel,
+		"system":     chatSystemInstruction,
+		"messages":   toAnthropicMessages(promptHistory),
+		"max_tokens": anthropicMaxTokens,
+		"stream":     true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal anthropic request: %w", err)
+	}
+
+	httpReq, err := p.newRequest(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic messages stream request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("anthropic messages stream returned status %d", resp.StatusCode)
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		defer resp.Body.Close()
+
+		usage := &TokenUsage{}
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+
+			var event struct {
+				Type  string `json:"type"`
+				Delta struct {
+					Text string `json:"text"`
+				} `json:"delta"`
+				Message struct {
+					Usage struct {
+						InputTokens int `json:"input_tokens"`
+					} `json:"usage"`
+				} `json:"message"`
+				Usage struct {
+					OutputTokens int `json:"output_tokens"`
+				} `json:"usage"`
+			}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				continue
+			}
+
+			switch event.Type {
+			case "message_start":
+				usage.PromptTokens = event.Message.Usage.InputTokens
+			case "content_block_delta":
+				if event.Delta.Text != "" {
+					if !send(ctx, out, StreamChunk{Text: event.Delta.Text}) {
+						return
+					}
+				}
+			case "message_delta":
+				if event.Usage.OutputTokens > 0 {
+					usage.CompletionTokens = event.Usage.OutputTokens
+				}
+			case "message_stop":
+				send(ctx, out, StreamChunk{Done: true, Usage: usage})
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			send(ctx, out, StreamChunk{Done: true, Err: fmt.Errorf("anthropic stream read failed: %w", err)})
+			return
+		}
+		send(ctx, out, StreamChunk{Done: true, Usage: usage})
+	}()
+
+	return out, nil
+}
+
+func (p *AnthropicProvider) GenerateTitleForChat(chatSummary string) (string, error) {
+	title, _, err := p.GetChatCompletion([]ChatMessage{
+		{Role: "user", Content: fmt.Sprintf("%s Generate a very concise title (3-5 words maximum) for a conversation that starts with or is about: \"%s\".", titleSystemInstruction, chatSummary)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("anthropic title generation request failed: %w", err)
+	}
+	title = strings.Trim(title, "\"'\n\r\t .")
+	if title == "" {
+		return "Chat", fmt.Errorf("LLM generated an empty title string")
+	}
+	return title, nil
+}
+
+func (p *AnthropicProvider) GeneratePromptStarters(chunks []string, limit int) ([]string, error) {
+	text, _, err := p.GetChatCompletion([]ChatMessage{{Role: "user", Content: buildPromptStarterRequest(chunks, limit)}})
+	if err != nil {
+		return nil, fmt.Errorf("anthropic prompt starter generation failed: %w", err)
+	}
+	starters := parsePromptStarters(text, limit)
+	if len(starters) == 0 {
+		return nil, fmt.Errorf("LLM did not generate any prompt starters")
+	}
+	return starters, nil
+}
+
+// GetChatCompletionWithTools does not yet support tool calling for the
+// Anthropic provider; it falls back to a plain completion when no tools are
+// requested and errors otherwise.
+func (p *AnthropicProvider) GetChatCompletionWithTools(promptHistory []ChatMessage, tools []agent.ToolSpec) (string, []ToolInvocation, *TokenUsage, error) {
+	if len(tools) == 0 {
+		text, usage, err := p.GetChatCompletion(promptHistory)
+		return text, nil, usage, err
+	}
+	return "", nil, nil, fmt.Errorf("tool calling is not yet supported by the anthropic provider")
+}
@@ -0,0 +1,452 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"gwi.com/jedi-team-challenge/internal/agent"
+	"gwi.com/jedi-team-challenge/internal/config"
+)
+
+// maxToolIterations bounds how many tool-call round trips GetChatCompletionWithTools
+// will make before giving up and returning a fixed apology message instead of an
+// answer, guarding against a model that keeps requesting tools indefinitely.
+const maxToolIterations = 5
+
+const (
+	defaultGeminiChatModelName      = "gemini-1.5-flash-latest"
+	defaultGeminiEmbeddingModelName = "text-embedding-004"
+	defaultGeminiTitleModelName     = "gemini-1.5-flash-latest"
+
+	chatSystemInstruction = "You are a helpful GWI assistant. Answer questions based on the provided market research data. " +
+		"If the answer is not found in the provided context, clearly state that you don't have the information. " +
+		"Keep your answers concise and directly related to the user's question and provided context. " +
+		"Do not make up information. If the context is insufficient, say so."
+
+	titleSystemInstruction = "You are a helpful assistant that generates concise titles for chat conversations. " +
+		"The title should be 3-5 words maximum. Just return the title itself, nothing else."
+)
+
+// GeminiProvider is the LLMProvider backed by Google's Gemini models.
+type GeminiProvider struct {
+	client        *genai.Client
+	chatModelName string
+}
+
+// NewGeminiProvider creates a Gemini-backed provider. If chatModelName is
+// empty, defaultGeminiChatModelName is used.
+func NewGeminiProvider(chatModelName string) *GeminiProvider {
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey(config.AppConfig.GeminiAPIKey))
+	if err != nil {
+		log.Fatalf("Failed to create GenAI client: %v", err)
+	}
+
+	if chatModelName == "" {
+		chatModelName = defaultGeminiChatModelName
+	}
+
+	return &GeminiProvider{
+		client:        client,
+		chatModelName: chatModelName,
+	}
+}
+
+func (s *GeminiProvider) Close() {
+	if s.client != nil {
+		if err := s.client.Close(); err != nil {
+			log.Printf("Error closing GenAI client: %v", err)
+		} else {
+			log.Println("GenAI client closed.")
+		}
+	}
+}
+
+func (s *GeminiProvider) GetEmbedding(text string) ([]float32, error) {
+	ctx := context.Background()
+	em := s.client.EmbeddingModel(defaultGeminiEmbeddingModelName)
+	res, err := em.EmbedContent(ctx, genai.Text(text))
+	if err != nil {
+		return nil, fmt.Errorf("gemini embedding request failed: %w", err)
+	}
+
+	if res.Embedding == nil || len(res.Embedding.Values) == 0 {
+		return nil, fmt.Errorf("no embedding data received from gemini")
+	}
+	return res.Embedding.Values, nil
+}
+
+// toGeminiContent translates the provider-agnostic history into genai's
+// wire format.
+func toGeminiContent(promptHistory []ChatMessage) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(promptHistory))
+	for _, msg := range promptHistory {
+		contents = append(contents, &genai.Content{
+			Role:  msg.Role,
+			Parts: []genai.Part{genai.Text(msg.Content)},
+		})
+	}
+	return contents
+}
+
+// geminiUsage extracts token counts from a Gemini response's UsageMetadata,
+// or nil if the API didn't report any.
+func geminiUsage(resp *genai.GenerateContentResponse) *TokenUsage {
+	if resp == nil || resp.UsageMetadata == nil {
+		return nil
+	}
+	return &TokenUsage{
+		PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
+		CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount),
+	}
+}
+
+func (s *GeminiProvider) GetChatCompletion(promptHistory []ChatMessage) (string, *TokenUsage, error) {
+	if len(promptHistory) == 0 {
+		return "", nil, fmt.Errorf("prompt history is empty for chat completion")
+	}
+	if promptHistory[len(promptHistory)-1].Role != "user" {
+		// This should ideally not happen if RAG service constructs correctly
+		return "", nil, fmt.Errorf("last message in history is not from 'user', cannot proceed with chat completion")
+	}
+
+	ctx := context.Background()
+	model := s.client.GenerativeModel(s.chatModelName)
+
+	model.SystemInstruction = &genai.Content{
+		Parts: []genai.Part{genai.Text(chatSystemInstruction)},
+	}
+
+	contents := toGeminiContent(promptHistory)
+	lastUserMessage := contents[len(contents)-1]
+
+	chatSession := model.StartChat()
+	chatSession.History = contents[:len(contents)-1]
+
+	resp, err := chatSession.SendMessage(ctx, lastUserMessage.Parts...)
+	if err != nil {
+		return "", nil, fmt.Errorf("gemini chat SendMessage failed: %w", err)
+	}
+	usage := geminiUsage(resp)
+
+	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		log.Println("Gemini response was empty or had no valid candidates/parts.")
+		return "I'm sorry, I couldn't generate a response at this time. Please try again.", usage, nil
+	}
+
+	var responseText strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if txt, ok := part.(genai.Text); ok {
+			responseText.WriteString(string(txt))
+		} else {
+			log.Printf("Gemini response part was not text: %T", part)
+		}
+	}
+
+	if responseText.Len() == 0 {
+		log.Println("Gemini response part was not text or was empty after processing.")
+		return "I received an empty or non-text response, please try rephrasing your question.", usage, nil
+	}
+
+	return responseText.String(), usage, nil
+}
+
+// GetChatCompletionStream behaves like GetChatCompletion but streams the
+// response token-by-token over the returned channel instead of waiting for
+// the full generation to complete. The channel is always closed, with the
+// final value carrying Done=true (and Err set if generation failed midway).
+// Cancelling ctx aborts the upstream Gemini call and stops the stream.
+func (s *GeminiProvider) GetChatCompletionStream(ctx context.Context, promptHistory []ChatMessage) (<-chan StreamChunk, error) {
+	if len(promptHistory) == 0 {
+		return nil, fmt.Errorf("prompt history is empty for chat completion")
+	}
+	if promptHistory[len(promptHistory)-1].Role != "user" {
+		// This should ideally not happen if RAG service constructs correctly
+		return nil, fmt.Errorf("last message in history is not from 'user', cannot proceed with chat completion")
+	}
+
+	model := s.client.GenerativeModel(s.chatModelName)
+	model.SystemInstruction = &genai.Content{
+		Parts: []genai.Part{genai.Text(chatSystemInstruction)},
+	}
+
+	contents := toGeminiContent(promptHistory)
+	lastUserMessage := contents[len(contents)-1]
+
+	chatSession := model.StartChat()
+	chatSession.History = contents[:len(contents)-1]
+
+	iter := chatSession.SendMessageStream(ctx, lastUserMessage.Parts...)
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		var usage *TokenUsage
+		for {
+			resp, err := iter.Next()
+			if err == iterator.Done {
+				send(ctx, out, StreamChunk{Done: true, Usage: usage})
+				return
+			}
+			if err != nil {
+				send(ctx, out, StreamChunk{Done: true, Err: fmt.Errorf("gemini stream failed: %w", err)})
+				return
+			}
+
+			if resp == nil {
+				continue
+			}
+			if resp.UsageMetadata != nil {
+				usage = &TokenUsage{
+					PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
+					CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount),
+				}
+			}
+			if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+				continue
+			}
+			for _, part := range resp.Candidates[0].Content.Parts {
+				if txt, ok := part.(genai.Text); ok && len(txt) > 0 {
+					if !send(ctx, out, StreamChunk{Text: string(txt)}) {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// send delivers a chunk unless ctx is cancelled first, returning false if the
+// caller went away so the producing goroutine can stop early.
+func send(ctx context.Context, out chan<- StreamChunk, chunk StreamChunk) bool {
+	select {
+	case out <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *GeminiProvider) GenerateTitleForChat(chatSummary string) (string, error) {
+	ctx := context.Background()
+	model := s.client.GenerativeModel(defaultGeminiTitleModelName)
+
+	model.SystemInstruction = &genai.Content{
+		Parts: []genai.Part{genai.Text(titleSystemInstruction)},
+	}
+
+	temp := float32(0.3)
+	maxTokens := int32(20)
+
+	model.GenerationConfig = genai.GenerationConfig{
+		MaxOutputTokens: &maxTokens,
+		Temperature:     &temp,
+	}
+
+	userPromptForTitle := fmt.Sprintf("Generate a very concise title (3-5 words maximum) for a conversation that starts with or is about: \"%s\".", chatSummary)
+
+	resp, err := model.GenerateContent(ctx, genai.Text(userPromptForTitle))
+	if err != nil {
+		return "", fmt.Errorf("gemini title generation request failed: %w", err)
+	}
+
+	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "Chat", fmt.Errorf("LLM did not generate a title (empty response)")
+	}
+
+	var titleText strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if txt, ok := part.(genai.Text); ok {
+			titleText.WriteString(string(txt))
+		}
+	}
+
+	if titleText.Len() == 0 {
+		return "Chat", fmt.Errorf("LLM generated an empty title string")
+	}
+
+	return strings.Trim(titleText.String(), "\"'\n\r\t ."), nil
+}
+
+func (s *GeminiProvider) GeneratePromptStarters(chunks []string, limit int) ([]string, error) {
+	text, _, err := s.GetChatCompletion([]ChatMessage{{Role: "user", Content: buildPromptStarterRequest(chunks, limit)}})
+	if err != nil {
+		return nil, fmt.Errorf("gemini prompt starter generation failed: %w", err)
+	}
+	starters := parsePromptStarters(text, limit)
+	if len(starters) == 0 {
+		return nil, fmt.Errorf("LLM did not generate any prompt starters")
+	}
+	return starters, nil
+}
+
+// GetChatCompletionWithTools advertises tools to Gemini's function-calling
+// API and loops over FunctionCall responses, invoking the matching
+// ToolSpec.Impl and feeding a FunctionResponse back, until Gemini returns
+// plain text or maxToolIterations is reached.
+func (s *GeminiProvider) GetChatCompletionWithTools(promptHistory []ChatMessage, tools []agent.ToolSpec) (string, []ToolInvocation, *TokenUsage, error) {
+	if len(promptHistory) == 0 {
+		return "", nil, nil, fmt.Errorf("prompt history is empty for chat completion")
+	}
+	if len(tools) == 0 {
+		text, usage, err := s.GetChatCompletion(promptHistory)
+		return text, nil, usage, err
+	}
+	if promptHistory[len(promptHistory)-1].Role != "user" {
+		return "", nil, nil, fmt.Errorf("last message in history is not from 'user', cannot proceed with chat completion")
+	}
+
+	ctx := context.Background()
+	model := s.client.GenerativeModel(s.chatModelName)
+	model.SystemInstruction = &genai.Content{
+		Parts: []genai.Part{genai.Text(chatSystemInstruction)},
+	}
+	model.Tools = []*genai.Tool{{FunctionDeclarations: toGeminiFunctionDeclarations(tools)}}
+
+	toolsByName := make(map[string]agent.ToolSpec, len(tools))
+	for _, t := range tools {
+		toolsByName[t.Name] = t
+	}
+
+	contents := toGeminiContent(promptHistory)
+	lastUserMessage := contents[len(contents)-1]
+
+	chatSession := model.StartChat()
+	chatSession.History = contents[:len(contents)-1]
+
+	var invocations []ToolInvocation
+	var usage *TokenUsage
+	parts := lastUserMessage.Parts
+
+	for i := 0; i < maxToolIterations; i++ {
+		resp, err := chatSession.SendMessage(ctx, parts...)
+		if err != nil {
+			return "", invocations, usage, fmt.Errorf("gemini chat SendMessage failed: %w", err)
+		}
+		usage = accumulateUsage(usage, geminiUsage(resp))
+		if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+			return "I'm sorry, I couldn't generate a response at this time. Please try again.", invocations, usage, nil
+		}
+
+		var responseText strings.Builder
+		var functionResponses []genai.Part
+		for _, part := range resp.Candidates[0].Content.Parts {
+			switch p := part.(type) {
+			case genai.Text:
+				responseText.WriteString(string(p))
+			case genai.FunctionCall:
+				spec, ok := toolsByName[p.Name]
+				result := ""
+				if !ok {
+					result = fmt.Sprintf("error: unknown tool %q", p.Name)
+				} else {
+					result, err = spec.Impl(p.Args)
+					if err != nil {
+						result = fmt.Sprintf("error: %v", err)
+					}
+				}
+				invocations = append(invocations, ToolInvocation{ToolName: p.Name, Args: p.Args, Result: result})
+				functionResponses = append(functionResponses, genai.FunctionResponse{
+					Name:     p.Name,
+					Response: map[string]interface{}{"result": result},
+				})
+			default:
+				log.Printf("Gemini response part of unexpected type: %T", part)
+			}
+		}
+
+		if len(functionResponses) == 0 {
+			if responseText.Len() == 0 {
+				return "I received an empty or non-text response, please try rephrasing your question.", invocations, usage, nil
+			}
+			return responseText.String(), invocations, usage, nil
+		}
+
+		parts = functionResponses
+	}
+
+	return "I wasn't able to finish gathering the information needed to answer that. Please try again.", invocations, usage, nil
+}
+
+// toGeminiFunctionDeclarations translates the provider-agnostic tool specs
+// into Gemini's function-calling declarations.
+func toGeminiFunctionDeclarations(tools []agent.ToolSpec) []*genai.FunctionDeclaration {
+	decls := make([]*genai.FunctionDeclaration, 0, len(tools))
+	for _, t := range tools {
+		decls = append(decls, &genai.FunctionDeclaration{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  jsonSchemaToGeminiSchema(t.Parameters),
+		})
+	}
+	return decls
+}
+
+// jsonSchemaToGeminiSchema converts a JSON-schema-shaped map (as used by
+// agent.ToolSpec.Parameters) into a *genai.Schema. It supports the subset of
+// JSON Schema the toolbox in this repo actually uses: type, description,
+// properties, items, and required.
+func jsonSchemaToGeminiSchema(schema map[string]interface{}) *genai.Schema {
+	if schema == nil {
+		return &genai.Schema{Type: genai.TypeObject}
+	}
+
+	out := &genai.Schema{Type: jsonSchemaType(schema["type"])}
+	if desc, ok := schema["description"].(string); ok {
+		out.Description = desc
+	}
+
+	if rawProps, ok := schema["properties"].(map[string]interface{}); ok {
+		props := make(map[string]*genai.Schema, len(rawProps))
+		for name, rawProp := range rawProps {
+			if propMap, ok := rawProp.(map[string]interface{}); ok {
+				props[name] = jsonSchemaToGeminiSchema(propMap)
+			}
+		}
+		out.Properties = props
+	}
+
+	if rawItems, ok := schema["items"].(map[string]interface{}); ok {
+		out.Items = jsonSchemaToGeminiSchema(rawItems)
+	}
+
+	switch required := schema["required"].(type) {
+	case []string:
+		out.Required = required
+	case []interface{}:
+		for _, r := range required {
+			if s, ok := r.(string); ok {
+				out.Required = append(out.Required, s)
+			}
+		}
+	}
+
+	return out
+}
+
+func jsonSchemaType(raw interface{}) genai.Type {
+	typeName, _ := raw.(string)
+	switch typeName {
+	case "string":
+		return genai.TypeString
+	case "number":
+		return genai.TypeNumber
+	case "integer":
+		return genai.TypeInteger
+	case "boolean":
+		return genai.TypeBoolean
+	case "array":
+		return genai.TypeArray
+	case "object":
+		return genai.TypeObject
+	default:
+		return genai.TypeUnspecified
+	}
+}
@@ -0,0 +1,246 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gwi.com/jedi-team-challenge/internal/agent"
+	"gwi.com/jedi-team-challenge/internal/config"
+)
+
+const (
+	defaultOllamaBaseURL        = "http://localhost:11434"
+	defaultOllamaChatModelName  = "llama3"
+	defaultOllamaEmbeddingModel = "nomic-embed-text"
+)
+
+// OllamaProvider is the LLMProvider backed by a locally (or self-) hosted
+// Ollama instance, for deployments that don't want to send data to a
+// third-party API.
+type OllamaProvider struct {
+	httpClient *http.Client
+	baseURL    string
+	chatModel  string
+}
+
+func NewOllamaProvider(chatModel string) *OllamaProvider {
+	if chatModel == "" {
+		chatModel = defaultOllamaChatModelName
+	}
+	baseURL := config.AppConfig.OllamaBaseURL
+	if baseURL == "" {
+		baseURL = defaultOllamaBaseURL
+	}
+	return &OllamaProvider{
+		httpClient: &http.Client{Timeout: 120 * time.Second},
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		chatModel:  chatModel,
+	}
+}
+
+func (p *OllamaProvider) Close() {}
+
+func (p *OllamaProvider) GetEmbedding(text string) ([]float32, error) {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model":  defaultOllamaEmbeddingModel,
+		"prompt": text,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ollama embedding request: %w", err)
+	}
+
+	resp, err := p.httpClient.Post(p.baseURL+"/api/embeddings", "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("ollama embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama embedding request returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode ollama embedding response: %w", err)
+	}
+	if len(result.Embedding) == 0 {
+		return nil, fmt.Errorf("no embedding data received from ollama")
+	}
+	return result.Embedding, nil
+}
+
+type ollamaChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func toOllamaMessages(promptHistory []ChatMessage) []ollamaChatMessage {
+	msgs := make([]ollamaChatMessage, 0, len(promptHistory)+1)
+	msgs = append(msgs, ollamaChatMessage{Role: "system", Content: chatSystemInstruction})
+	for _, m := range promptHistory {
+		role := m.Role
+		if role == "model" {
+			role = "assistant"
+		}
+		msgs = append(msgs, ollamaChatMessage{Role: role, Content: m.Content})
+	}
+	return msgs
+}
+
+func (p *OllamaProvider) GetChatCompletion(promptHistory []ChatMessage) (string, *TokenUsage, error) {
+	if len(promptHistory) == 0 {
+		return "", nil, fmt.Errorf("prompt history is empty for chat completion")
+	}
+
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model":    p.chatModel,
+		"messages": toOllamaMessages(promptHistory),
+		"stream":   false,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal ollama chat request: %w", err)
+	}
+
+	resp, err := p.httpClient.Post(p.baseURL+"/api/chat", "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", nil, fmt.Errorf("ollama chat request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("ollama chat request returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Message         ollamaChatMessage `json:"message"`
+		PromptEvalCount int               `json:"prompt_eval_count"`
+		EvalCount       int               `json:"eval_count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", nil, fmt.Errorf("failed to decode ollama chat response: %w", err)
+	}
+	usage := &TokenUsage{PromptTokens: result.PromptEvalCount, CompletionTokens: result.EvalCount}
+	if result.Message.Content == "" {
+		return "I'm sorry, I couldn't generate a response at this time. Please try again.", usage, nil
+	}
+	return result.Message.Content, usage, nil
+}
+
+func (p *OllamaProvider) GetChatCompletionStream(ctx context.Context, promptHistory []ChatMessage) (<-chan StreamChunk, error) {
+	if len(promptHistory) == 0 {
+		return nil, fmt.Errorf("prompt history is empty for chat completion")
+	}
+
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model":    p.chatModel,
+		"messages": toOllamaMessages(promptHistory),
+		"stream":   true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ollama chat request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/chat", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build ollama request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ollama chat stream request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("ollama chat stream returned status %d", resp.StatusCode)
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		defer resp.Body.Close()
+
+		// Ollama streams newline-delimited JSON objects, not SSE.
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			var event struct {
+				Message         ollamaChatMessage `json:"message"`
+				Done            bool              `json:"done"`
+				PromptEvalCount int               `json:"prompt_eval_count"`
+				EvalCount       int               `json:"eval_count"`
+			}
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				continue
+			}
+			if event.Message.Content != "" {
+				if !send(ctx, out, StreamChunk{Text: event.Message.Content}) {
+					return
+				}
+			}
+			if event.Done {
+				send(ctx, out, StreamChunk{Done: true, Usage: &TokenUsage{
+					PromptTokens:     event.PromptEvalCount,
+					CompletionTokens: event.EvalCount,
+				}})
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			send(ctx, out, StreamChunk{Done: true, Err: fmt.Errorf("ollama stream read failed: %w", err)})
+			return
+		}
+		send(ctx, out, StreamChunk{Done: true})
+	}()
+
+	return out, nil
+}
+
+func (p *OllamaProvider) GenerateTitleForChat(chatSummary string) (string, error) {
+	title, _, err := p.GetChatCompletion([]ChatMessage{
+		{Role: "user", Content: fmt.Sprintf("%s Generate a very concise title (3-5 words maximum) for a conversation that starts with or is about: \"%s\".", titleSystemInstruction, chatSummary)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("ollama title generation request failed: %w", err)
+	}
+	title = strings.Trim(title, "\"'\n\r\t .")
+	if title == "" {
+		return "Chat", fmt.Errorf("LLM generated an empty title string")
+	}
+	return title, nil
+}
+
+func (p *OllamaProvider) GeneratePromptStarters(chunks []string, limit int) ([]string, error) {
+	text, _, err := p.GetChatCompletion([]ChatMessage{{Role: "user", Content: buildPromptStarterRequest(chunks, limit)}})
+	if err != nil {
+		return nil, fmt.Errorf("ollama prompt starter generation failed: %w", err)
+	}
+	starters := parsePromptStarters(text, limit)
+	if len(starters) == 0 {
+		return nil, fmt.Errorf("LLM did not generate any prompt starters")
+	}
+	return starters, nil
+}
+
+// GetChatCompletionWithTools does not yet support tool calling for the
+// Ollama provider (support varies by model); it falls back to a plain
+// completion when no tools are requested and errors otherwise.
+func (p *OllamaProvider) GetChatCompletionWithTools(promptHistory []ChatMessage, tools []agent.ToolSpec) (string, []ToolInvocation, *TokenUsage, error) {
+	if len(tools) == 0 {
+		text, usage, err := p.GetChatCompletion(promptHistory)
+		return text, nil, usage, err
+	}
+	return "", nil, nil, fmt.Errorf("tool calling is not yet supported by the ollama provider")
+}
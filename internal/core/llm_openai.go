@@ -0,0 +1,284 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gwi.com/jedi-team-challenge/internal/agent"
+	"gwi.com/jedi-team-challenge/internal/config"
+)
+
+const (
+	defaultOpenAIBaseURL        = "https://api.openai.com/v1"
+	defaultOpenAIChatModelName  = "gpt-4o-mini"
+	defaultOpenAIEmbeddingModel = "text-embedding-3-small"
+)
+
+// OpenAIProvider is the LLMProvider backed by OpenAI's chat completions and
+// embeddings APIs.
+type OpenAIProvider struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	chatModel  string
+}
+
+func NewOpenAIProvider(chatModel string) *OpenAIProvider {
+	if chatModel == "" {
+		chatModel = defaultOpenAIChatModelName
+	}
+	baseURL := config.AppConfig.OpenAIBaseURL
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	return &OpenAIProvider{
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     config.AppConfig.OpenAIAPIKey,
+		chatModel:  chatModel,
+	}
+}
+
+func (p *OpenAIProvider) Close() {}
+
+type openAIChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func toOpenAIMessages(promptHistory []ChatMessage) []openAIChatMessage {
+	msgs := make([]openAIChatMessage, 0, len(promptHistory)+1)
+	msgs = append(msgs, openAIChatMessage{Role: "system", Content: chatSystemInstruction})
+	for _, m := range promptHistory {
+		role := m.Role
+		if role == "model" {
+			role = "assistant"
+		}
+		msgs = append(msgs, openAIChatMessage{Role: role, Content: m.Content})
+	}
+	return msgs
+}
+
+func (p *OpenAIProvider) GetEmbedding(text string) ([]float32, error) {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model": defaultOpenAIEmbeddingModel,
+		"input": text,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal openai embedding request: %w", err)
+	}
+
+	var result struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := p.doJSON(context.Background(), "/embeddings", reqBody, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) == 0 || len(result.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("no embedding data received from openai")
+	}
+	return result.Data[0].Embedding, nil
+}
+
+func (p *OpenAIProvider) GetChatCompletion(promptHistory []ChatMessage) (string, *TokenUsage, error) {
+	if len(promptHistory) == 0 {
+		return "", nil, fmt.Errorf("prompt history is empty for chat completion")
+	}
+
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model":    p.chatModel,
+		"messages": toOpenAIMessages(promptHistory),
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal openai chat request: %w", err)
+	}
+
+	var result struct {
+		Choices []struct {
+			Message openAIChatMessage `json:"message"`
+		} `json:"choices"`
+		Usage *struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := p.doJSON(context.Background(), "/chat/completions", reqBody, &result); err != nil {
+		return "", nil, err
+	}
+
+	var usage *TokenUsage
+	if result.Usage != nil {
+		usage = &TokenUsage{PromptTokens: result.Usage.PromptTokens, CompletionTokens: result.Usage.CompletionTokens}
+	}
+
+	if len(result.Choices) == 0 {
+		return "I'm sorry, I couldn't generate a response at this time. Please try again.", usage, nil
+	}
+	return result.Choices[0].Message.Content, usage, nil
+}
+
+func (p *OpenAIProvider) GetChatCompletionStream(ctx context.Context, promptHistory []ChatMessage) (<-chan StreamChunk, error) {
+	if len(promptHistory) == 0 {
+		return nil, fmt.Errorf("prompt history is empty for chat completion")
+	}
+
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model":    p.chatModel,
+		"messages": toOpenAIMessages(promptHistory),
+		"stream":   true,
+		// include_usage adds one extra chunk after the final content delta,
+		// with empty Choices and Usage populated, carrying token counts for
+		// the whole completion.
+		"stream_options": map[string]interface{}{"include_usage": true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal openai chat request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build openai request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("openai chat stream request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("openai chat stream returned status %d", resp.StatusCode)
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		defer resp.Body.Close()
+
+		var usage *TokenUsage
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			data := strings.TrimPrefix(line, "data: ")
+			if data == "[DONE]" {
+				send(ctx, out, StreamChunk{Done: true, Usage: usage})
+				return
+			}
+
+			var event struct {
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+				} `json:"choices"`
+				Usage *struct {
+					PromptTokens     int `json:"prompt_tokens"`
+					CompletionTokens int `json:"completion_tokens"`
+				} `json:"usage"`
+			}
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				continue
+			}
+			if event.Usage != nil {
+				usage = &TokenUsage{PromptTokens: event.Usage.PromptTokens, CompletionTokens: event.Usage.CompletionTokens}
+			}
+			if len(event.Choices) > 0 && event.Choices[0].Delta.Content != "" {
+				if !send(ctx, out, StreamChunk{Text: event.Choices[0].Delta.Content}) {
+					return
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			send(ctx, out, StreamChunk{Done: true, Err: fmt.Errorf("openai stream read failed: %w", err)})
+			return
+		}
+		send(ctx, out, StreamChunk{Done: true, Usage: usage})
+	}()
+
+	return out, nil
+}
+
+func (p *OpenAIProvider) GenerateTitleForChat(chatSummary string) (string, error) {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model": p.chatModel,
+		"messages": []openAIChatMessage{
+			{Role: "system", Content: titleSystemInstruction},
+			{Role: "user", Content: fmt.Sprintf("Generate a very concise title (3-5 words maximum) for a conversation that starts with or is about: \"%s\".", chatSummary)},
+		},
+		"max_tokens": 20,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal openai title request: %w", err)
+	}
+
+	var result struct {
+		Choices []struct {
+			Message openAIChatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := p.doJSON(context.Background(), "/chat/completions", reqBody, &result); err != nil {
+		return "", err
+	}
+	if len(result.Choices) == 0 {
+		return "Chat", fmt.Errorf("LLM did not generate a title (empty response)")
+	}
+	return strings.Trim(result.Choices[0].Message.Content, "\"'\n\r\t ."), nil
+}
+
+func (p *OpenAIProvider) doJSON(ctx context.Context, path string, body []byte, out interface{}) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build openai request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("openai request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("openai request to %s returned status %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode openai response: %w", err)
+	}
+	return nil
+}
+
+func (p *OpenAIProvider) GeneratePromptStarters(chunks []string, limit int) ([]string, error) {
+	text, _, err := p.GetChatCompletion([]ChatMessage{{Role: "user", Content: buildPromptStarterRequest(chunks, limit)}})
+	if err != nil {
+		return nil, fmt.Errorf("openai prompt starter generation failed: %w", err)
+	}
+	starters := parsePromptStarters(text, limit)
+	if len(starters) == 0 {
+		return nil, fmt.Errorf("LLM did not generate any prompt starters")
+	}
+	return starters, nil
+}
+
+// GetChatCompletionWithTools does not yet support tool calling for the
+// OpenAI provider; it falls back to a plain completion when no tools are
+// requested and errors otherwise.
+func (p *OpenAIProvider) GetChatCompletionWithTools(promptHistory []ChatMessage, tools []agent.ToolSpec) (string, []ToolInvocation, *TokenUsage, error) {
+	if len(tools) == 0 {
+		text, usage, err := p.GetChatCompletion(promptHistory)
+		return text, nil, usage, err
+	}
+	return "", nil, nil, fmt.Errorf("tool calling is not yet supported by the openai provider")
+}
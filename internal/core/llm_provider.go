@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"gwi.com/jedi-team-challenge/internal/agent"
+	"gwi.com/jedi-team-challenge/internal/config"
+)
+
+// ChatMessage is a single provider-agnostic chat turn. RAGService and
+// ChatService build histories out of these instead of any one backend's SDK
+// types, so swapping LLMProvider implementations never leaks into callers.
+type ChatMessage struct {
+	Role    string // "user" or "model"
+	Content string
+}
+
+// StreamChunk is one piece of a streamed chat completion, as produced by
+// GetChatCompletionStream. The final chunk of a successful generation has
+// Done set; a chunk with Err set is also terminal. A provider whose upstream
+// call is cancelled mid-stream (ctx.Done fires inside its send helper) may
+// close the channel without ever sending a Done or Err chunk.
+type StreamChunk struct {
+	Text string
+	Done bool
+	Err  error
+	// Usage reports token counts for the completion, when the provider's API
+	// surfaces them; set on the Done chunk only. Nil means the backend didn't
+	// report usage for this call.
+	Usage *TokenUsage
+}
+
+// TokenUsage reports how many tokens a completion consumed. Not every
+// provider reports every field on every call.
+type TokenUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// accumulateUsage sums running and next, tolerating either being nil (a
+// provider call that didn't report usage), for providers whose
+// GetChatCompletionWithTools loop makes more than one LLM call per reply.
+func accumulateUsage(running, next *TokenUsage) *TokenUsage {
+	if next == nil {
+		return running
+	}
+	if running == nil {
+		usage := *next
+		return &usage
+	}
+	return &TokenUsage{
+		PromptTokens:     running.PromptTokens + next.PromptTokens,
+		CompletionTokens: running.CompletionTokens + next.CompletionTokens,
+	}
+}
+
+// LLMProvider is implemented by every supported LLM backend. Concrete
+// implementations own their own request/response translation to and from
+// ChatMessage/StreamChunk.
+type LLMProvider interface {
+	GetEmbedding(text string) ([]float32, error)
+	// GetChatCompletion returns the model's reply and, when the provider's
+	// API reports it, the token usage for the call.
+	GetChatCompletion(promptHistory []ChatMessage) (string, *TokenUsage, error)
+	GetChatCompletionStream(ctx context.Context, promptHistory []ChatMessage) (<-chan StreamChunk, error)
+	GenerateTitleForChat(chatSummary string) (string, error)
+
+	// GeneratePromptStarters asks the model for up to limit concise,
+	// user-phrased questions answerable from the given corpus excerpts.
+	GeneratePromptStarters(chunks []string, limit int) ([]string, error)
+
+	// GetChatCompletionWithTools behaves like GetChatCompletion, but
+	// advertises tools to the model and loops: whenever the model asks for a
+	// tool call, the matching ToolSpec.Impl runs and its result is fed back,
+	// until the model returns a final text answer (or the iteration cap is
+	// hit). Each invocation made along the way is returned so the caller can
+	// persist it to the transcript. If tools is empty this is equivalent to
+	// GetChatCompletion. Usage sums token counts across every LLM call made
+	// during the loop, not just the final one.
+	GetChatCompletionWithTools(promptHistory []ChatMessage, tools []agent.ToolSpec) (text string, invocations []ToolInvocation, usage *TokenUsage, err error)
+
+	Close()
+}
+
+// ToolInvocation records one tool call made by the model during
+// GetChatCompletionWithTools, for persisting to the chat transcript.
+type ToolInvocation struct {
+	ToolName string
+	Args     map[string]interface{}
+	Result   string
+}
+
+// NewLLMProvider builds the LLMProvider selected by config.AppConfig.LLMProvider.
+// If EmbeddingProvider names a different backend, embeddings are delegated to
+// a second provider instance while chat/title generation stay on the primary.
+func NewLLMProvider() LLMProvider {
+	primary := newProviderByName(config.AppConfig.LLMProvider, config.AppConfig.LLMModel)
+
+	embeddingProvider := config.AppConfig.EmbeddingProvider
+	if embeddingProvider == "" || strings.EqualFold(embeddingProvider, config.AppConfig.LLMProvider) {
+		return primary
+	}
+
+	return &embeddingOverrideProvider{
+		LLMProvider: primary,
+		embedder:    newProviderByName(embeddingProvider, config.AppConfig.EmbeddingModel),
+	}
+}
+
+func newProviderByName(name, model string) LLMProvider {
+	switch strings.ToLower(name) {
+	case "", "gemini":
+		return NewGeminiProvider(model)
+	case "openai":
+		return NewOpenAIProvider(model)
+	case "anthropic":
+		return NewAnthropicProvider(model)
+	case "ollama":
+		return NewOllamaProvider(model)
+	default:
+		log.Fatalf("Unknown LLM provider %q", name)
+		return nil // unreachable
+	}
+}
+
+// embeddingOverrideProvider lets embeddings come from a different backend
+// than chat completion/title generation, without forcing every LLMProvider
+// implementation to know about the split.
+type embeddingOverrideProvider struct {
+	LLMProvider
+	embedder LLMProvider
+}
+
+func (p *embeddingOverrideProvider) GetEmbedding(text string) ([]float32, error) {
+	return p.embedder.GetEmbedding(text)
+}
+
+func (p *embeddingOverrideProvider) Close() {
+	p.LLMProvider.Close()
+	p.embedder.Close()
+}
+
+// buildPromptStarterRequest renders the corpus excerpts and instructions
+// every provider sends the model to generate prompt starters, so each
+// implementation only owns its own transport.
+func buildPromptStarterRequest(chunks []string, limit int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Based on the following market research excerpts, write exactly %d concise, user-phrased questions that a person could ask a chatbot and have answered from this data. Return one question per line, with no numbering, bullets, or extra commentary.\n\n", limit)
+	for i, chunk := range chunks {
+		fmt.Fprintf(&b, "Excerpt %d: %s\n", i+1, chunk)
+	}
+	return b.String()
+}
+
+// parsePromptStarters extracts up to limit non-empty lines from the model's
+// response, stripping any numbering/bullets it added despite instructions.
+func parsePromptStarters(text string, limit int) []string {
+	starters := make([]string, 0, limit)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimLeft(line, "0123456789.-)* \t")
+		if line == "" {
+			continue
+		}
+		starters = append(starters, line)
+		if len(starters) == limit {
+			break
+		}
+	}
+	return starters
+}
@@ -1,14 +1,16 @@
 package core
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
-	"sort"
 	"strings"
+	"time"
 
-	"github.com/google/generative-ai-go/genai"
+	"gwi.com/jedi-team-challenge/internal/agent"
+	"gwi.com/jedi-team-challenge/internal/metrics"
 	"gwi.com/jedi-team-challenge/internal/store"
-	"gwi.com/jedi-team-challenge/internal/utils"
 )
 
 const (
@@ -17,87 +19,125 @@ const (
 )
 
 type RAGService struct {
-	dbStore    *store.SQLiteStore
-	llmService *LLMService
-	dataChunks []store.DataChunk // In-memory cache of data chunks and their embeddings
+	dbStore    store.Store
+	llmService LLMProvider
+	tools      *agent.Registry // Optional toolbox advertised to the model; nil/empty disables tool-calling.
+	metrics    *metrics.Registry
 }
 
-func NewRAGService(db *store.SQLiteStore, llm *LLMService) (*RAGService, error) {
-	chunks, err := db.GetAllDataChunks()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load data chunks for RAG service: %w", err)
-	}
-	if len(chunks) == 0 {
-		log.Println("Warning: RAGService initialized with no data chunks. Ensure data has been ingested with the current embedding model.")
+func NewRAGService(db store.Store, llm LLMProvider, reg *metrics.Registry) (*RAGService, error) {
+	if n := db.VectorIndexSize(); n == 0 {
+		log.Println("Warning: RAGService initialized with an empty vector index. Ensure data has been ingested with the current embedding model.")
 	} else {
-		log.Printf("RAGService initialized with %d data chunks.", len(chunks))
+		log.Printf("RAGService initialized; vector index holds %d data chunks.", n)
 	}
 
 	return &RAGService{
 		dbStore:    db,
 		llmService: llm,
-		dataChunks: chunks,
+		metrics:    reg,
 	}, nil
 }
 
-type ScoredChunk struct {
-	Chunk      store.DataChunk
-	Similarity float32
+// SetToolRegistry wires an agent toolbox into the service. Once set, future
+// calls to GenerateResponse advertise the registered tools to the model and
+// persist any invocations it makes to the chat transcript.
+func (s *RAGService) SetToolRegistry(registry *agent.Registry) {
+	s.tools = registry
 }
 
+// GetRelevantContext embeds query, retrieves candidates from both the dense
+// HNSW index (store.Store.SearchChunks) and lexical full-text search
+// (store.Store.SearchChunksBM25), and fuses the two ranked lists with
+// Reciprocal Rank Fusion. Combining the two catches keyword-heavy queries
+// (proper nouns, acronyms) that cosine similarity over embeddings alone
+// tends to rank poorly, without giving up semantic matching for queries
+// that are nothing like the chunk's exact wording.
 func (s *RAGService) GetRelevantContext(query string) (string, error) {
-	if len(s.dataChunks) == 0 {
-		log.Println("No data chunks available for RAG context retrieval.")
-		return "", nil // No context if no data
-	}
+	retrievalStart := time.Now()
+	defer func() { s.metrics.ObserveRetrievalLatency(time.Since(retrievalStart)) }()
+	s.metrics.IncRAGRetrieval()
 
+	embeddingStart := time.Now()
 	queryEmbedding, err := s.llmService.GetEmbedding(query)
+	s.metrics.ObserveEmbeddingLatency(time.Since(embeddingStart))
 	if err != nil {
+		s.metrics.IncLLMError("embedding")
 		return "", fmt.Errorf("failed to get query embedding: %w", err)
 	}
 
-	scoredChunks := make([]ScoredChunk, 0, len(s.dataChunks))
-	for _, chunk := range s.dataChunks {
-		if len(chunk.Embedding) == 0 {
-			log.Printf("Skipping chunk ID %d due to missing embedding.", chunk.ID)
-			continue
-		}
-		similarity, err := utils.CosineSimilarity(queryEmbedding, chunk.Embedding)
-		if err != nil {
-			log.Printf("Error calculating similarity for chunk %d with query: %v. Skipping.", chunk.ID, err)
-			continue // Skip this chunk
-		}
+	denseHits, err := s.dbStore.SearchChunks(queryEmbedding, rrfCandidatePoolSize, SimilarityThreshold)
+	if err != nil {
+		return "", fmt.Errorf("failed to search vector index: %w", err)
+	}
 
-		if similarity >= SimilarityThreshold { // Only consider similar chunks over the threshold
-			scoredChunks = append(scoredChunks, ScoredChunk{Chunk: chunk, Similarity: similarity})
-		}
+	lexicalHits, err := s.dbStore.SearchChunksBM25(query, rrfCandidatePoolSize)
+	if err != nil {
+		// Lexical search is a complement to the dense index, not a hard
+		// dependency; fall back to dense-only rather than failing the request.
+		log.Printf("Full-text search failed, falling back to dense retrieval only: %v", err)
+		lexicalHits = nil
+	}
+
+	fused := fuseRankedLists(denseHits, lexicalHits)
+	if len(fused) > NumRelevantChunks {
+		fused = fused[:NumRelevantChunks]
 	}
 
-	// Sort by similarity in descending order
-	sort.Slice(scoredChunks, func(i, j int) bool {
-		return scoredChunks[i].Similarity > scoredChunks[j].Similarity
-	})
+	if len(fused) == 0 {
+		log.Printf("No relevant chunks found for query: %s", query)
+		return "", nil
+	}
 
 	var contextBuilder strings.Builder
-	retrievedCount := 0
-	for i := 0; i < len(scoredChunks) && retrievedCount < NumRelevantChunks; i++ {
-		contextBuilder.WriteString(scoredChunks[i].Chunk.Content)
+	for _, sc := range fused {
+		contextBuilder.WriteString(sc.Chunk.Content)
 		contextBuilder.WriteString("\n\n") // Separate chunks clearly
-		retrievedCount++
 	}
 
-	if retrievedCount == 0 {
-		log.Printf("No relevant chunks found for query (Similarity threshold: %.2f): %s", SimilarityThreshold, query)
-		return "", nil // No relevant context found meeting the threshold
+	log.Printf("Retrieved %d relevant chunks for query (hybrid dense+BM25).", len(fused))
+	return strings.TrimSpace(contextBuilder.String()), nil
+}
+
+// buildPromptHistory assembles the provider-agnostic chat history (prior
+// turns plus the current query, optionally grounded with RAG context) that
+// both GenerateResponse and GenerateResponseStream hand off to the LLMProvider.
+func buildPromptHistory(chatHistoryMsgs []store.Message, relevantContext, userQuery string) []ChatMessage {
+	history := make([]ChatMessage, 0, len(chatHistoryMsgs)+1)
+	for _, msg := range chatHistoryMsgs {
+		if msg.Sender == "tool" {
+			// Tool invocations are persisted in the branch chain so the
+			// transcript shows reasoning steps (see persistToolInvocations),
+			// but no provider's chat history accepts a "tool" role turn -
+			// only "user"/"model" - so they're dropped here rather than sent
+			// upstream. The model's own reply already reflects what it
+			// learned from the tool call.
+			continue
+		}
+		history = append(history, ChatMessage{Role: msg.Sender, Content: msg.Content})
+	}
+
+	var finalUserContent string
+	if relevantContext != "" {
+		finalUserContent = fmt.Sprintf("Based on our previous conversation and the following potentially relevant context from GWI market research data:\n\n--- CONTEXT START ---\n%s\n--- CONTEXT END ---\n\nNow, please answer my question: %s", relevantContext, userQuery)
+	} else {
+		finalUserContent = fmt.Sprintf("Based on our previous conversation (if any), and noting that I couldn't find specific GWI documents for your current question, please answer: %s", userQuery)
 	}
+	history = append(history, ChatMessage{Role: "user", Content: finalUserContent})
 
-	log.Printf("Retrieved %d relevant chunks for query.", retrievedCount)
-	return strings.TrimSpace(contextBuilder.String()), nil
+	return history
 }
 
-func (s *RAGService) GenerateResponse(chatID string, userID int64, userQuery string) (string, error) {
-	// 1. Retrieve chat history (last few messages)
-	chatHistoryMsgs, err := s.dbStore.GetLastNMessagesByChatID(chatID, 5) // Get last 5 messages (in order to avoid too long history)
+// GenerateResponse produces the assistant's reply to userQuery within the
+// given branch. parentMessageID is the message (normally the just-stored
+// user message) that the reply, and any tool invocations made along the way,
+// should be threaded from; it returns the message ID the final reply was
+// actually threaded from, which is parentMessageID itself unless tool calls
+// were persisted in between, and the token usage the LLM call reported (nil
+// if the provider didn't report any).
+func (s *RAGService) GenerateResponse(chatID string, userID int64, branchID, parentMessageID, userQuery string) (string, string, *TokenUsage, error) {
+	// 1. Retrieve chat history (last few messages) for this branch.
+	chatHistoryMsgs, err := s.dbStore.GetLastNMessagesByBranch(chatID, branchID, 5) // Get last 5 messages (in order to avoid too long history)
 	if err != nil {
 		log.Printf("Error getting chat history for chat %s: %v. Proceeding without history.", chatID, err)
 		chatHistoryMsgs = []store.Message{}
@@ -112,36 +152,135 @@ func (s *RAGService) GenerateResponse(chatID string, userID int64, userQuery str
 		relevantContext = "" // Ensure it's an empty string
 	}
 
-	// 3. Construct prompt for Gemini
-	// The SystemInstruction is set on the model in LLMService.
-	var geminiChatHistory []*genai.Content
+	// 3. Construct prompt history and get a response from the LLM.
+	promptHistory := buildPromptHistory(chatHistoryMsgs, relevantContext, userQuery)
 
-	// Add chat history to prompt
-	for _, msg := range chatHistoryMsgs {
-		geminiChatHistory = append(geminiChatHistory, &genai.Content{
-			Role:  msg.Sender,
-			Parts: []genai.Part{genai.Text(msg.Content)},
+	generationStart := time.Now()
+
+	if s.tools != nil && s.tools.Len() > 0 {
+		modelResponse, invocations, usage, err := s.llmService.GetChatCompletionWithTools(promptHistory, s.tools.All())
+		s.metrics.ObserveGenerationLatency(time.Since(generationStart))
+		if err != nil {
+			s.metrics.IncLLMError("generation")
+			return "", parentMessageID, nil, fmt.Errorf("failed to get LLM completion: %w", err)
+		}
+		newParentMessageID := s.persistToolInvocations(chatID, branchID, parentMessageID, invocations)
+		return modelResponse, newParentMessageID, usage, nil
+	}
+
+	modelResponse, usage, err := s.llmService.GetChatCompletion(promptHistory)
+	s.metrics.ObserveGenerationLatency(time.Since(generationStart))
+	if err != nil {
+		s.metrics.IncLLMError("generation")
+		return "", parentMessageID, nil, fmt.Errorf("failed to get LLM completion: %w", err)
+	}
+
+	return modelResponse, parentMessageID, usage, nil
+}
+
+// persistToolInvocations records each tool call the model made while
+// producing a response as a "tool" sender message, chaining them off
+// parentMessageID in order so the chat transcript shows the reasoning steps
+// alongside the final answer. It returns the ID the final reply should be
+// threaded from: the last tool message if any were persisted, otherwise
+// parentMessageID unchanged.
+func (s *RAGService) persistToolInvocations(chatID, branchID, parentMessageID string, invocations []ToolInvocation) string {
+	parent := parentMessageID
+	for _, inv := range invocations {
+		payload, err := json.Marshal(map[string]interface{}{
+			"name":   inv.ToolName,
+			"args":   inv.Args,
+			"result": inv.Result,
 		})
+		if err != nil {
+			log.Printf("Failed to marshal tool invocation %q for chat %s: %v", inv.ToolName, chatID, err)
+			continue
+		}
+		toolCallJSON := string(payload)
+		parentCopy := parent
+
+		msg := store.Message{
+			ChatID:          chatID,
+			Sender:          "tool",
+			Content:         inv.Result,
+			ToolCall:        &toolCallJSON,
+			ParentMessageID: &parentCopy,
+			BranchID:        branchID,
+		}
+		if err := persistMessage(s.dbStore, s.metrics, &msg); err != nil {
+			log.Printf("Failed to persist tool invocation message for chat %s: %v", chatID, err)
+			continue
+		}
+		parent = msg.ID
 	}
+	return parent
+}
 
-	// Add RAG context and current user query as the last "user" turn
-	finalUserContent := ""
-	if relevantContext != "" {
-		finalUserContent = fmt.Sprintf("Based on our previous conversation and the following potentially relevant context from GWI market research data:\n\n--- CONTEXT START ---\n%s\n--- CONTEXT END ---\n\nNow, please answer my question: %s", relevantContext, userQuery)
-	} else {
-		finalUserContent = fmt.Sprintf("Based on our previous conversation (if any), and noting that I couldn't find specific GWI documents for your current question, please answer: %s", userQuery)
+// GenerateEvent is one event of a streamed reply from GenerateResponseStream.
+// Type is one of "context", "token", "done", or "error"; the context event
+// is always sent first, once, before any tokens, so a caller can surface
+// what RAG context (if any) was retrieved ahead of generation starting. If
+// the underlying stream is cancelled mid-generation, the channel closes
+// without ever sending a "done" or "error" event.
+type GenerateEvent struct {
+	Type    string
+	Context string
+	Token   string
+	Err     error
+	// Usage is set on the "done" event when the provider reported token
+	// counts for the completion; nil otherwise.
+	Usage *TokenUsage
+}
+
+// GenerateResponseStream mirrors GenerateResponse but streams the model's
+// reply over the returned channel as it is generated, so callers can forward
+// tokens to a client instead of waiting for the full completion. Cancelling
+// ctx propagates through to the underlying LLM call.
+func (s *RAGService) GenerateResponseStream(ctx context.Context, chatID string, userID int64, branchID, userQuery string) (<-chan GenerateEvent, error) {
+	chatHistoryMsgs, err := s.dbStore.GetLastNMessagesByBranch(chatID, branchID, 5)
+	if err != nil {
+		log.Printf("Error getting chat history for chat %s: %v. Proceeding without history.", chatID, err)
+		chatHistoryMsgs = []store.Message{}
 	}
 
-	geminiChatHistory = append(geminiChatHistory, &genai.Content{
-		Role:  "user",
-		Parts: []genai.Part{genai.Text(finalUserContent)},
-	})
+	relevantContext, err := s.GetRelevantContext(userQuery)
+	if err != nil {
+		log.Printf("Failed to get relevant context, proceeding without it: %v", err)
+		relevantContext = ""
+	}
+
+	promptHistory := buildPromptHistory(chatHistoryMsgs, relevantContext, userQuery)
 
-	// 4. Get response from LLM
-	modelResponse, err := s.llmService.GetChatCompletion(geminiChatHistory)
+	generationStart := time.Now()
+	chunks, err := s.llmService.GetChatCompletionStream(ctx, promptHistory)
 	if err != nil {
-		return "", fmt.Errorf("failed to get LLM completion: %w", err)
+		s.metrics.IncLLMError("generation")
+		return nil, err
 	}
 
-	return modelResponse, nil
+	out := make(chan GenerateEvent)
+	go func() {
+		defer close(out)
+		out <- GenerateEvent{Type: "context", Context: relevantContext}
+
+		for chunk := range chunks {
+			switch {
+			case chunk.Err != nil:
+				s.metrics.ObserveGenerationLatency(time.Since(generationStart))
+				s.metrics.IncLLMError("generation")
+				out <- GenerateEvent{Type: "error", Err: chunk.Err}
+				return
+			case chunk.Done:
+				s.metrics.ObserveGenerationLatency(time.Since(generationStart))
+				out <- GenerateEvent{Type: "done", Usage: chunk.Usage}
+				return
+			case chunk.Text != "":
+				out <- GenerateEvent{Type: "token", Token: chunk.Text}
+			}
+		}
+		// chunks closed without a terminal Done/Err chunk: the upstream call
+		// was cancelled mid-stream rather than finishing or failing outright.
+	}()
+
+	return out, nil
 }
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"sort"
+
+	"gwi.com/jedi-team-challenge/internal/store"
+)
+
+// rrfCandidatePoolSize is how many hits GetRelevantContext pulls from each
+// of the dense and lexical retrievers before fusing, well above
+// NumRelevantChunks so a chunk that's merely decent in both lists can still
+// outrank one that's only great in one.
+const rrfCandidatePoolSize = 10
+
+// rrfK0 is the Reciprocal Rank Fusion rank constant: a chunk ranked i-th
+// (1-based) in a list contributes 1/(rrfK0+i) to its fused score. 60 is the
+// value from the original RRF paper; absent any tuning data of our own
+// there's no reason to deviate from it.
+const rrfK0 = 60
+
+// fuseRankedLists combines two independently-ranked chunk lists with
+// Reciprocal Rank Fusion, returning the union ordered by fused score
+// descending. A chunk appearing in both lists sums a contribution from
+// each; one appearing in only one list is still included, scored on that
+// list alone.
+func fuseRankedLists(dense, lexical []store.ScoredChunk) []store.ScoredChunk {
+	type candidate struct {
+		chunk store.DataChunk
+		score float64
+	}
+	byID := make(map[int64]*candidate)
+	order := make([]int64, 0, len(dense)+len(lexical))
+
+	add := func(list []store.ScoredChunk) {
+		for rank, sc := range list {
+			c, ok := byID[sc.Chunk.ID]
+			if !ok {
+				c = &candidate{chunk: sc.Chunk}
+				byID[sc.Chunk.ID] = c
+				order = append(order, sc.Chunk.ID)
+			}
+			c.score += 1.0 / float64(rrfK0+rank+1)
+		}
+	}
+	add(dense)
+	add(lexical)
+
+	fused := make([]store.ScoredChunk, len(order))
+	for i, id := range order {
+		c := byID[id]
+		fused[i] = store.ScoredChunk{Chunk: c.chunk, Similarity: float32(c.score)}
+	}
+	sort.Slice(fused, func(i, j int) bool { return fused[i].Similarity > fused[j].Similarity })
+	return fused
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gwi.com/jedi-team-challenge/internal/store"
+)
+
+// DefaultMessageSchedulerInterval is how often MessageScheduler checks for
+// due scheduled messages when no interval is configured.
+const DefaultMessageSchedulerInterval = 10 * time.Second
+
+// MessageScheduler periodically promotes scheduled messages (created via
+// ChatService.ScheduleMessage) into live ones once their delivery time
+// arrives, threading each onto whatever branch is current for its chat at
+// that moment and generating the model's reply.
+type MessageScheduler struct {
+	chatService *ChatService
+	dbStore     store.Store
+	interval    time.Duration
+}
+
+// NewMessageScheduler builds a MessageScheduler that ticks every interval; an
+// interval <= 0 falls back to DefaultMessageSchedulerInterval.
+func NewMessageScheduler(cs *ChatService, db store.Store, interval time.Duration) *MessageScheduler {
+	if interval <= 0 {
+		interval = DefaultMessageSchedulerInterval
+	}
+	return &MessageScheduler{chatService: cs, dbStore: db, interval: interval}
+}
+
+// Run ticks every interval, delivering due messages, until ctx is cancelled.
+func (s *MessageScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.deliverDue()
+		}
+	}
+}
+
+// deliverDue promotes every message whose ScheduledAt has passed. Each
+// delivery is independent; one failing is logged and skipped rather than
+// blocking the rest of the batch.
+func (s *MessageScheduler) deliverDue() {
+	due, err := s.dbStore.MessagesDue(time.Now())
+	if err != nil {
+		log.Printf("MessageScheduler: failed to query due messages: %v", err)
+		return
+	}
+
+	for _, msg := range due {
+		if err := s.deliver(msg); err != nil {
+			log.Printf("MessageScheduler: failed to deliver scheduled message %s: %v", msg.ID, err)
+		}
+	}
+}
+
+// deliver threads a single scheduled message onto its chat's current branch
+// tip, then generates and stores the model's reply, the same way
+// ChatService.PostMessage does for a message posted live.
+func (s *MessageScheduler) deliver(msg store.Message) error {
+	userID, err := s.dbStore.GetChatOwnerID(msg.ChatID)
+	if err != nil {
+		return err
+	}
+	return s.chatService.DeliverScheduledMessage(msg, userID)
+}
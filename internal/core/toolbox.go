@@ -0,0 +1,120 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"gwi.com/jedi-team-challenge/internal/agent"
+	"gwi.com/jedi-team-challenge/internal/config"
+	"gwi.com/jedi-team-challenge/internal/store"
+)
+
+// NewDefaultToolRegistry builds the toolbox advertised to the model,
+// skipping any tool disabled via config.AppConfig.EnabledTools.
+func NewDefaultToolRegistry(rag *RAGService, dbStore store.Store) *agent.Registry {
+	registry := agent.NewRegistry()
+
+	candidates := []agent.ToolSpec{
+		searchMarketDataTool(rag),
+		getChatHistoryTool(dbStore),
+		currentTimeTool(),
+	}
+
+	for _, tool := range candidates {
+		if config.AppConfig.ToolEnabled(tool.Name) {
+			registry.Register(tool)
+		}
+	}
+
+	return registry
+}
+
+func searchMarketDataTool(rag *RAGService) agent.ToolSpec {
+	return agent.ToolSpec{
+		Name:        "search_market_data",
+		Description: "Search the ingested GWI market research corpus for content relevant to a query.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "The search query.",
+				},
+			},
+			"required": []string{"query"},
+		},
+		Impl: func(args map[string]interface{}) (string, error) {
+			query, _ := args["query"].(string)
+			if query == "" {
+				return "", fmt.Errorf("search_market_data requires a non-empty 'query' argument")
+			}
+			context, err := rag.GetRelevantContext(query)
+			if err != nil {
+				return "", fmt.Errorf("search_market_data failed: %w", err)
+			}
+			if context == "" {
+				return "No relevant market data found for that query.", nil
+			}
+			return context, nil
+		},
+	}
+}
+
+func getChatHistoryTool(dbStore store.Store) agent.ToolSpec {
+	return agent.ToolSpec{
+		Name:        "get_chat_history",
+		Description: "Fetch recent messages from the current chat for additional context.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"chat_id": map[string]interface{}{
+					"type":        "string",
+					"description": "The ID of the chat to read history from.",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of recent messages to return (default 10).",
+				},
+			},
+			"required": []string{"chat_id"},
+		},
+		Impl: func(args map[string]interface{}) (string, error) {
+			chatID, _ := args["chat_id"].(string)
+			if chatID == "" {
+				return "", fmt.Errorf("get_chat_history requires a non-empty 'chat_id' argument")
+			}
+			limit := 10
+			if l, ok := args["limit"].(float64); ok && l > 0 {
+				limit = int(l)
+			}
+
+			messages, err := dbStore.GetLastNMessagesByChatID(chatID, limit)
+			if err != nil {
+				return "", fmt.Errorf("get_chat_history failed: %w", err)
+			}
+
+			var summary string
+			for i := len(messages) - 1; i >= 0; i-- {
+				summary += fmt.Sprintf("%s: %s\n", messages[i].Sender, messages[i].Content)
+			}
+			if summary == "" {
+				return "No prior messages in this chat.", nil
+			}
+			return summary, nil
+		},
+	}
+}
+
+func currentTimeTool() agent.ToolSpec {
+	return agent.ToolSpec{
+		Name:        "current_time",
+		Description: "Get the current UTC date and time.",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		Impl: func(args map[string]interface{}) (string, error) {
+			return time.Now().UTC().Format(time.RFC3339), nil
+		},
+	}
+}
@@ -0,0 +1,234 @@
+// Package metrics collects per-request HTTP latency and exposes it in the
+// Prometheus text exposition format, without pulling in the Prometheus
+// client library.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// requestLatencyBuckets are the upper bounds, in seconds, of the request
+// duration histogram, following Prometheus's usual default progression.
+var requestLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type routeKey struct {
+	method string
+	route  string
+	status int
+}
+
+// histogram holds cumulative bucket counts, mirroring Prometheus's "le"
+// (less-than-or-equal) histogram semantics.
+type histogram struct {
+	buckets []uint64 // cumulative count for each entry in requestLatencyBuckets
+	sum     float64
+	count   uint64
+}
+
+// Registry collects per-route request counts and latencies and renders them
+// on demand for a scraper.
+type Registry struct {
+	mu         sync.Mutex
+	histograms map[routeKey]*histogram
+
+	chatMessagesTotal  map[string]uint64 // keyed by message sender ("user", "model", "tool")
+	ragRetrievalsTotal uint64
+	llmErrorsTotal     map[string]uint64 // keyed by stage ("embedding", "generation")
+
+	embeddingLatency  *histogram
+	retrievalLatency  *histogram
+	generationLatency *histogram
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		histograms:        make(map[routeKey]*histogram),
+		chatMessagesTotal: make(map[string]uint64),
+		llmErrorsTotal:    make(map[string]uint64),
+		embeddingLatency:  &histogram{buckets: make([]uint64, len(requestLatencyBuckets))},
+		retrievalLatency:  &histogram{buckets: make([]uint64, len(requestLatencyBuckets))},
+		generationLatency: &histogram{buckets: make([]uint64, len(requestLatencyBuckets))},
+	}
+}
+
+// observe records duration into h against requestLatencyBuckets. Callers
+// must hold r.mu.
+func observe(h *histogram, duration time.Duration) {
+	seconds := duration.Seconds()
+	for i, upperBound := range requestLatencyBuckets {
+		if seconds <= upperBound {
+			h.buckets[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// IncChatMessage increments the count of chat messages persisted, keyed by
+// sender ("user", "model", or "tool").
+func (r *Registry) IncChatMessage(sender string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chatMessagesTotal[sender]++
+}
+
+// IncRAGRetrieval increments the count of RAG context retrievals performed
+// (one per GetRelevantContext call, regardless of whether any chunks came
+// back relevant).
+func (r *Registry) IncRAGRetrieval() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ragRetrievalsTotal++
+}
+
+// IncLLMError increments the count of failed LLM provider calls, keyed by
+// stage ("embedding" or "generation").
+func (r *Registry) IncLLMError(stage string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.llmErrorsTotal[stage]++
+}
+
+// ObserveEmbeddingLatency records how long a GetEmbedding call took.
+func (r *Registry) ObserveEmbeddingLatency(duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	observe(r.embeddingLatency, duration)
+}
+
+// ObserveRetrievalLatency records how long a full GetRelevantContext call
+// (embedding plus dense and lexical search) took.
+func (r *Registry) ObserveRetrievalLatency(duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	observe(r.retrievalLatency, duration)
+}
+
+// ObserveGenerationLatency records how long an LLM chat completion call
+// took.
+func (r *Registry) ObserveGenerationLatency(duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	observe(r.generationLatency, duration)
+}
+
+// Observe records one completed request's route, status, and latency.
+func (r *Registry) Observe(method, route string, status int, duration time.Duration) {
+	key := routeKey{method: method, route: route, status: status}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.histograms[key]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(requestLatencyBuckets))}
+		r.histograms[key] = h
+	}
+	observe(h, duration)
+}
+
+// Middleware times each request and records it against the matched chi
+// route pattern (e.g. "/api/chats/{chatID}"), so metrics stay low-cardinality
+// even under path parameters.
+func (r *Registry) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, req.ProtoMajor)
+
+		next.ServeHTTP(ww, req)
+
+		route := chi.RouteContext(req.Context()).RoutePattern()
+		if route == "" {
+			route = req.URL.Path
+		}
+		r.Observe(req.Method, route, ww.Status(), time.Since(start))
+	})
+}
+
+// Handler renders the current metrics in the Prometheus text exposition
+// format, suitable for mounting at /metrics.
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		keys := make([]routeKey, 0, len(r.histograms))
+		for k := range r.histograms {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].route != keys[j].route {
+				return keys[i].route < keys[j].route
+			}
+			if keys[i].method != keys[j].method {
+				return keys[i].method < keys[j].method
+			}
+			return keys[i].status < keys[j].status
+		})
+
+		var b strings.Builder
+		b.WriteString("# HELP http_request_duration_seconds Latency of HTTP requests in seconds.\n")
+		b.WriteString("# TYPE http_request_duration_seconds histogram\n")
+		for _, k := range keys {
+			h := r.histograms[k]
+			labels := fmt.Sprintf(`method="%s",route="%s",status="%d"`, k.method, k.route, k.status)
+			for i, upperBound := range requestLatencyBuckets {
+				fmt.Fprintf(&b, "http_request_duration_seconds_bucket{%s,le=\"%g\"} %d\n", labels, upperBound, h.buckets[i])
+			}
+			fmt.Fprintf(&b, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+			fmt.Fprintf(&b, "http_request_duration_seconds_sum{%s} %g\n", labels, h.sum)
+			fmt.Fprintf(&b, "http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+		}
+
+		writeCounter(&b, "chat_messages_total", "Chat messages persisted, by sender.", "sender", r.chatMessagesTotal)
+		writeCounter(&b, "llm_errors_total", "Failed LLM provider calls, by stage.", "stage", r.llmErrorsTotal)
+
+		b.WriteString("# HELP rag_retrievals_total Total number of RAG context retrievals performed.\n")
+		b.WriteString("# TYPE rag_retrievals_total counter\n")
+		fmt.Fprintf(&b, "rag_retrievals_total %d\n", r.ragRetrievalsTotal)
+
+		writeHistogram(&b, "llm_embedding_duration_seconds", "Latency of embedding calls in seconds.", r.embeddingLatency)
+		writeHistogram(&b, "rag_retrieval_duration_seconds", "Latency of RAG context retrieval (embedding plus search) in seconds.", r.retrievalLatency)
+		writeHistogram(&b, "llm_generation_duration_seconds", "Latency of LLM chat completion calls in seconds.", r.generationLatency)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write([]byte(b.String()))
+	}
+}
+
+// writeCounter renders a single-label counter metric, sorting its keys for
+// stable scrape output.
+func writeCounter(b *strings.Builder, name, help, labelName string, counts map[string]uint64) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s counter\n", name)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s{%s=\"%s\"} %d\n", name, labelName, k, counts[k])
+	}
+}
+
+// writeHistogram renders an unlabelled histogram metric against
+// requestLatencyBuckets.
+func writeHistogram(b *strings.Builder, name, help string, h *histogram) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s histogram\n", name)
+	for i, upperBound := range requestLatencyBuckets {
+		fmt.Fprintf(b, "%s_bucket{le=\"%g\"} %d\n", name, upperBound, h.buckets[i])
+	}
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(b, "%s_sum %g\n", name, h.sum)
+	fmt.Fprintf(b, "%s_count %d\n", name, h.count)
+}
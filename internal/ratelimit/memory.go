@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type bucketEntry struct {
+	key     string
+	limiter *rate.Limiter
+}
+
+// InMemoryLimiter is a Limiter backed by an in-process, bounded
+// least-recently-used map of token buckets, good enough for a single server
+// instance. See the package doc for swapping in a Redis-backed Limiter to
+// enforce the same policies across more than one.
+type InMemoryLimiter struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+// NewInMemoryLimiter returns an InMemoryLimiter holding at most capacity
+// buckets, evicting the least-recently-used one once full. A bucket is one
+// (policy, key) pair, so capacity bounds memory against an attacker cycling
+// through many distinct IPs or user IDs.
+func NewInMemoryLimiter(capacity int) *InMemoryLimiter {
+	return &InMemoryLimiter{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (l *InMemoryLimiter) Allow(key string, policy Policy) (bool, time.Duration) {
+	bucketKey := policy.Name + ":" + key
+
+	l.mu.Lock()
+	rl := l.bucketFor(bucketKey, policy)
+	l.mu.Unlock()
+
+	reservation := rl.Reserve()
+	if !reservation.OK() {
+		// Burst is always policy.Limit, so a single event never fails to
+		// reserve; treat it as allowed rather than blocking forever.
+		return true, 0
+	}
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
+
+// bucketFor returns the existing token bucket for bucketKey, or creates one
+// per policy's limit and window, moving it to the front of the LRU list.
+func (l *InMemoryLimiter) bucketFor(bucketKey string, policy Policy) *rate.Limiter {
+	if el, ok := l.items[bucketKey]; ok {
+		l.ll.MoveToFront(el)
+		return el.Value.(*bucketEntry).limiter
+	}
+
+	perEvent := policy.Window / time.Duration(policy.Limit)
+	rl := rate.NewLimiter(rate.Every(perEvent), policy.Limit)
+
+	el := l.ll.PushFront(&bucketEntry{key: bucketKey, limiter: rl})
+	l.items[bucketKey] = el
+
+	if l.ll.Len() > l.capacity {
+		oldest := l.ll.Back()
+		l.ll.Remove(oldest)
+		delete(l.items, oldest.Value.(*bucketEntry).key)
+	}
+
+	return rl
+}
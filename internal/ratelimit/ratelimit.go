@@ -0,0 +1,26 @@
+// Package ratelimit provides a per-key token-bucket rate limiter backing
+// api.RateLimitMiddleware and the login abuse controls. Limiter is an
+// interface so InMemoryLimiter (a single process's bucket map) can later be
+// swapped for a Redis-backed implementation without any caller changing,
+// once the service runs behind more than one instance.
+package ratelimit
+
+import "time"
+
+// Policy describes a token-bucket limit: at most Limit events per Window,
+// refilling gradually rather than all at once. Name distinguishes this
+// policy's buckets from any other policy sharing the same key (e.g. a user
+// ID rate-limited separately for chat creation and message posting).
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// Limiter grants or denies one event for key under policy.
+type Limiter interface {
+	// Allow reports whether one event for key is permitted under policy. If
+	// not, retryAfter is how long the caller should wait before trying
+	// again.
+	Allow(key string, policy Policy) (allowed bool, retryAfter time.Duration)
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// cursorPayload is the decoded form of an opaque pagination cursor: the
+// (created_at, id) of the last item a page of results ended on, so the next
+// page can resume from exactly that point regardless of rows inserted in
+// between.
+type cursorPayload struct {
+	Time time.Time `json:"t"`
+	ID   string    `json:"id"`
+}
+
+// EncodeCursor builds an opaque pagination cursor from the last row a page
+// ended on. Callers should treat the result as opaque; only DecodeCursor
+// should ever parse it back.
+func EncodeCursor(createdAt time.Time, id string) string {
+	raw, _ := json.Marshal(cursorPayload{Time: createdAt, ID: id})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses a cursor produced by EncodeCursor. An empty cursor
+// decodes to the zero time and empty id, meaning "start from the beginning".
+func DecodeCursor(cursor string) (createdAt time.Time, id string, err error) {
+	if cursor == "" {
+		return time.Time{}, "", nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid cursor encoding: %w", err)
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid cursor payload: %w", err)
+	}
+	return payload.Time, payload.ID, nil
+}
@@ -0,0 +1,301 @@
+package store
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"gwi.com/jedi-team-challenge/internal/utils"
+)
+
+// HNSW tuning parameters. M is the number of bidirectional links each node
+// keeps per layer; efConstruction is the candidate list size used while
+// inserting (wider = better recall, slower ingest); hnswDefaultEf is the
+// candidate list size used at query time.
+const (
+	hnswM              = 16
+	hnswEfConstruction = 200
+	hnswDefaultEf      = 64
+)
+
+// hnswNode is one point in the index: its embedding and, per layer, the IDs
+// of the neighbors it's bidirectionally connected to.
+type hnswNode struct {
+	ID        int64           `json:"id"`
+	Vector    []float32       `json:"vector"`
+	Neighbors map[int][]int64 `json:"neighbors"` // layer -> neighbor chunk IDs
+}
+
+// hnswIndex is a pure-Go Hierarchical Navigable Small World graph over data
+// chunk embeddings. It's what SearchChunks queries instead of scanning every
+// row, so the corpus can grow into the hundreds of thousands of chunks
+// without a linear rescan on every turn.
+type hnswIndex struct {
+	mu sync.RWMutex
+
+	nodes      map[int64]*hnswNode
+	entryPoint int64
+	maxLevel   int
+
+	m              int     // bidirectional links kept per node per layer (M)
+	efConstruction int     // candidate list size used while inserting
+	mL             float64 // level-generation normalization factor, 1/ln(M)
+}
+
+// hnswPersisted is the JSON shape hnswIndex is saved/loaded as.
+type hnswPersisted struct {
+	Nodes      map[int64]*hnswNode `json:"nodes"`
+	EntryPoint int64               `json:"entry_point"`
+	MaxLevel   int                 `json:"max_level"`
+}
+
+func newHNSWIndex() *hnswIndex {
+	return &hnswIndex{
+		nodes:          make(map[int64]*hnswNode),
+		maxLevel:       -1,
+		m:              hnswM,
+		efConstruction: hnswEfConstruction,
+		mL:             1 / math.Log(float64(hnswM)),
+	}
+}
+
+// candidate pairs a node ID with its distance from some query vector.
+type candidate struct {
+	id   int64
+	dist float32
+}
+
+// minDistHeap pops the closest candidate first; used to drive the beam
+// search's exploration frontier.
+type minDistHeap []candidate
+
+func (h minDistHeap) Len() int            { return len(h) }
+func (h minDistHeap) Less(i, j int) bool  { return h[i].dist < h[j].dist }
+func (h minDistHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *minDistHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *minDistHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// maxDistHeap pops the farthest candidate first; used to track the current
+// ef nearest-so-far results so the worst one can be evicted cheaply.
+type maxDistHeap []candidate
+
+func (h maxDistHeap) Len() int            { return len(h) }
+func (h maxDistHeap) Less(i, j int) bool  { return h[i].dist > h[j].dist }
+func (h maxDistHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *maxDistHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *maxDistHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// cosineDistance turns utils.CosineSimilarity into a distance (0 = identical)
+// so it composes with the min/max-heaps above. Vectors that can't be
+// compared (e.g. dimension mismatch) are treated as maximally distant rather
+// than failing the whole search.
+func cosineDistance(a, b []float32) float32 {
+	sim, err := utils.CosineSimilarity(a, b)
+	if err != nil {
+		return 1
+	}
+	return 1 - sim
+}
+
+// randomLevel samples an insertion level from the geometric distribution
+// HNSW relies on to keep each layer roughly M times smaller than the one
+// below it, parameterized by mL = 1/ln(M).
+func (idx *hnswIndex) randomLevel() int {
+	return int(math.Floor(-math.Log(rand.Float64()) * idx.mL))
+}
+
+// searchLayer runs a beam search of width ef for the neighbors of query
+// within a single layer, starting from entryPoints. Candidates not yet
+// explored are kept in a min-heap; the best ef seen so far are kept in a
+// max-heap so the worst can be evicted as better ones are found.
+func (idx *hnswIndex) searchLayer(query []float32, entryPoints []int64, ef, layer int) []candidate {
+	visited := make(map[int64]bool, len(entryPoints))
+	frontier := &minDistHeap{}
+	results := &maxDistHeap{}
+
+	for _, ep := range entryPoints {
+		if visited[ep] {
+			continue
+		}
+		visited[ep] = true
+		node, ok := idx.nodes[ep]
+		if !ok {
+			continue
+		}
+		d := cosineDistance(query, node.Vector)
+		heap.Push(frontier, candidate{ep, d})
+		heap.Push(results, candidate{ep, d})
+	}
+
+	for frontier.Len() > 0 {
+		nearest := heap.Pop(frontier).(candidate)
+		if results.Len() >= ef && nearest.dist > (*results)[0].dist {
+			break // nothing closer than our worst kept result remains to explore
+		}
+
+		for _, neighborID := range idx.nodes[nearest.id].Neighbors[layer] {
+			if visited[neighborID] {
+				continue
+			}
+			visited[neighborID] = true
+
+			neighbor, ok := idx.nodes[neighborID]
+			if !ok {
+				continue
+			}
+			d := cosineDistance(query, neighbor.Vector)
+			if results.Len() < ef || d < (*results)[0].dist {
+				heap.Push(frontier, candidate{neighborID, d})
+				heap.Push(results, candidate{neighborID, d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	out := make([]candidate, results.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(results).(candidate)
+	}
+	return out
+}
+
+// selectNeighborsHeuristic prunes candidates down to m, preferring ones that
+// are closer to the query than to any neighbor already picked. This is
+// HNSW's neighbor-diversity heuristic: a plain "m closest" selection tends to
+// cluster every edge in one direction, which hurts recall for queries that
+// approach a node from elsewhere in the graph.
+func (idx *hnswIndex) selectNeighborsHeuristic(candidates []candidate, m int) []candidate {
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+
+	selected := make([]candidate, 0, m)
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		diverse := true
+		for _, s := range selected {
+			if cosineDistance(idx.nodes[c.id].Vector, idx.nodes[s.id].Vector) < c.dist {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c)
+		}
+	}
+	return selected
+}
+
+// Insert wires a new vector into the graph: descend greedily from the entry
+// point down to the node's own sampled level using a single nearest
+// candidate per layer, then from there down to layer 0 run a beam search
+// with efConstruction and connect bidirectionally to the m best, diverse
+// neighbors, pruning any neighbor that now has more than m edges.
+func (idx *hnswIndex) Insert(id int64, vector []float32) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	node := &hnswNode{ID: id, Vector: vector, Neighbors: make(map[int][]int64)}
+	idx.nodes[id] = node
+
+	if len(idx.nodes) == 1 {
+		idx.entryPoint = id
+		idx.maxLevel = idx.randomLevel()
+		return
+	}
+
+	level := idx.randomLevel()
+	entryPoints := []int64{idx.entryPoint}
+
+	for l := idx.maxLevel; l > level; l-- {
+		nearest := idx.searchLayer(vector, entryPoints, 1, l)
+		if len(nearest) > 0 {
+			entryPoints = []int64{nearest[0].id}
+		}
+	}
+
+	top := level
+	if idx.maxLevel < top {
+		top = idx.maxLevel
+	}
+	for l := top; l >= 0; l-- {
+		found := idx.searchLayer(vector, entryPoints, idx.efConstruction, l)
+		neighbors := idx.selectNeighborsHeuristic(found, idx.m)
+
+		ids := make([]int64, len(neighbors))
+		for i, n := range neighbors {
+			ids[i] = n.id
+		}
+		node.Neighbors[l] = ids
+
+		for _, n := range neighbors {
+			other := idx.nodes[n.id]
+			other.Neighbors[l] = append(other.Neighbors[l], id)
+			if len(other.Neighbors[l]) > idx.m {
+				pruneCandidates := make([]candidate, len(other.Neighbors[l]))
+				for i, nb := range other.Neighbors[l] {
+					pruneCandidates[i] = candidate{nb, cosineDistance(other.Vector, idx.nodes[nb].Vector)}
+				}
+				kept := idx.selectNeighborsHeuristic(pruneCandidates, idx.m)
+				keptIDs := make([]int64, len(kept))
+				for i, k := range kept {
+					keptIDs[i] = k.id
+				}
+				other.Neighbors[l] = keptIDs
+			}
+		}
+
+		entryPoints = ids
+	}
+
+	if level > idx.maxLevel {
+		idx.maxLevel = level
+		idx.entryPoint = id
+	}
+}
+
+// Search returns up to k nodes nearest to query: the same greedy descent used
+// by Insert, followed by a layer-0 beam search of width ef (raised to at
+// least k).
+func (idx *hnswIndex) Search(query []float32, k, ef int) []candidate {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if len(idx.nodes) == 0 {
+		return nil
+	}
+	if ef < k {
+		ef = k
+	}
+
+	entryPoints := []int64{idx.entryPoint}
+	for l := idx.maxLevel; l > 0; l-- {
+		nearest := idx.searchLayer(query, entryPoints, 1, l)
+		if len(nearest) > 0 {
+			entryPoints = []int64{nearest[0].id}
+		}
+	}
+
+	found := idx.searchLayer(query, entryPoints, ef, 0)
+	sort.Slice(found, func(i, j int) bool { return found[i].dist < found[j].dist })
+	if len(found) > k {
+		found = found[:k]
+	}
+	return found
+}
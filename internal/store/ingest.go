@@ -0,0 +1,111 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// parseMarkdownChunks extracts the content cells of a single-column Markdown
+// table (skipping its header/separator rows), one row per returned chunk.
+// Shared by every Store implementation's IngestDataFromFile.
+func parseMarkdownChunks(fileContent string) []string {
+	lines := strings.Split(fileContent, "\n")
+
+	var rawChunks []string
+	for i, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+		if trimmedLine == "" {
+			continue // Skip empty lines
+		}
+
+		// Skip table header and separator
+		if i == 0 && strings.Contains(trimmedLine, "|") && (strings.Contains(strings.ToLower(trimmedLine), "text") || strings.Contains(strings.ToLower(trimmedLine), "content")) {
+			log.Printf("Skipping table header: %s", trimmedLine)
+			continue
+		}
+		if i == 1 && strings.Contains(trimmedLine, "|") && strings.Contains(trimmedLine, "---") {
+			log.Printf("Skipping table separator: %s", trimmedLine)
+			continue
+		}
+
+		// Basic parsing for a single-column Markdown table row: | some content |
+		if strings.HasPrefix(trimmedLine, "|") && strings.HasSuffix(trimmedLine, "|") {
+			parts := strings.Split(trimmedLine, "|")
+			// Expect 3 parts: "" (before first |), " content ", "" (after last |)
+			if len(parts) >= 3 {
+				cellContent := strings.TrimSpace(parts[1])
+				if cellContent != "" {
+					rawChunks = append(rawChunks, cellContent)
+				} else {
+					log.Printf("Skipping row with empty cell content: %s", trimmedLine)
+				}
+			} else {
+				log.Printf("Skipping malformed table row (not enough '|'): %s", trimmedLine)
+			}
+		} else if i > 1 {
+			// If it's not a table row after the header, skip it.
+			log.Printf("Skipping line not matching table row format: %s", trimmedLine)
+		}
+	}
+	return rawChunks
+}
+
+// readDataFile reads filePath and extracts its Markdown-table chunks. A
+// well-formed file with no content rows returns zero chunks, not an error.
+func readDataFile(filePath string) ([]string, error) {
+	contentBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file %s: %w", filePath, err)
+	}
+
+	rawChunks := parseMarkdownChunks(string(contentBytes))
+	if len(rawChunks) == 0 {
+		log.Println("No chunks generated from data file. Ensure it's a Markdown table with a 'text' column and content.")
+		return nil, nil
+	}
+	log.Printf("Generated %d raw chunks from table. Now embedding (this may take a while)...", len(rawChunks))
+	return rawChunks, nil
+}
+
+// ingestChunks embeds each raw chunk and hands it to create, rate-limited the
+// same way regardless of backend. clear runs first so a re-ingest replaces
+// rather than appends to the corpus; persistIndex is called once at the end
+// to save the vector index rebuilt along the way.
+func ingestChunks(rawChunks []string, embedder func(string) ([]float32, error), clear func() error, create func(*DataChunk) error, persistIndex func() error) (int, error) {
+	if err := clear(); err != nil {
+		return 0, fmt.Errorf("failed to clear existing data chunks: %w", err)
+	}
+
+	count := 0
+	ticker := time.NewTicker(40 * time.Millisecond) // delay to not hit rate limit (1500/min)
+	defer ticker.Stop()
+
+	for i, rawChunk := range rawChunks {
+		<-ticker.C
+
+		embedding, err := embedder(rawChunk)
+		if err != nil {
+			log.Printf("Failed to generate embedding for chunk %d (\"%.50s...\"): %v. Skipping.", i+1, rawChunk, err)
+			continue
+		}
+
+		chunk := DataChunk{Content: rawChunk, Embedding: embedding}
+		if err := create(&chunk); err != nil {
+			log.Printf("Failed to store data chunk %d: %v. Skipping.", i+1, err)
+			continue
+		}
+		count++
+		if count%10 == 0 || count == len(rawChunks) {
+			log.Printf("Ingested %d/%d chunks...", count, len(rawChunks))
+		}
+	}
+	log.Printf("Successfully ingested %d chunks.", count)
+
+	if err := persistIndex(); err != nil {
+		log.Printf("Warning: failed to persist rebuilt vector index: %v", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFS embed.FS
+
+// migration is one numbered schema change for a single driver. down is
+// loaded alongside up so a future rollback command has it available, even
+// though runMigrations itself only ever applies up.
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// loadMigrations reads every numbered *.up.sql/*.down.sql pair under
+// migrations/<driver>, ordered by version.
+func loadMigrations(driver string) ([]migration, error) {
+	dir := "migrations/" + driver
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations for driver %q: %w", driver, err)
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var suffix string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			suffix = ".up.sql"
+		case strings.HasSuffix(name, ".down.sql"):
+			suffix = ".down.sql"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, suffix)
+		versionStr, _, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("malformed migration filename %q", name)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("malformed migration version in %q: %w", name, err)
+		}
+
+		content, err := migrationFS.ReadFile(dir + "/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: base}
+			byVersion[version] = m
+		}
+		if suffix == ".up.sql" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// runMigrations applies every not-yet-applied up migration for driver
+// against db, in version order, recording progress in a schema_migrations
+// table so it's safe to call on every startup.
+func runMigrations(db *sql.DB, driver string) error {
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)"); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan schema_migrations row: %w", err)
+		}
+		applied[v] = true
+	}
+	rows.Close()
+
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return err
+	}
+
+	recordSQL := "INSERT INTO schema_migrations (version) VALUES (?)"
+	if driver == "postgres" {
+		recordSQL = "INSERT INTO schema_migrations (version) VALUES ($1)"
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %s: %w", m.name, err)
+		}
+		if _, err := tx.Exec(m.up); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %s: %w", m.name, err)
+		}
+		if _, err := tx.Exec(recordSQL, m.version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %w", m.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %w", m.name, err)
+		}
+	}
+	return nil
+}
@@ -2,11 +2,45 @@ package store
 
 import "time"
 
+// Role values for User.Role.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID             int64     `json:"id"`
 	ExternalUserID string    `json:"external_user_id"`
 	PasswordHash   string    `json:"-"` // Do not expose this in JSON responses
 	CreatedAt      time.Time `json:"created_at"`
+	// Role is RoleUser or RoleAdmin. The very first user ever created is
+	// promoted to RoleAdmin automatically (see CreateUser); everyone after
+	// that defaults to RoleUser.
+	Role string `json:"role"`
+	// Disabled marks an account an admin has locked out. JWTAuthMiddleware
+	// rejects every request from a disabled user even with an otherwise
+	// valid token.
+	Disabled bool `json:"disabled"`
+	// TokenVersion is embedded in every JWT issued to this user. Bumping it
+	// (BumpUserTokenVersion) invalidates every access token issued before the
+	// bump. It does not touch the user's Sessions: an unrevoked session's
+	// refresh token can still mint a new access token.
+	TokenVersion int `json:"-"`
+}
+
+// Session backs one refresh token issued at login. Its ID is embedded as the
+// "sid" claim in every access token minted from it, so JWTAuthMiddleware can
+// reject requests from a session that's been revoked or has expired without
+// waiting for the (much shorter-lived) access token to expire on its own.
+type Session struct {
+	ID               string     `json:"id"`
+	UserID           int64      `json:"-"`
+	RefreshTokenHash string     `json:"-"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	UserAgent        string     `json:"user_agent,omitempty"`
+	IP               string     `json:"ip,omitempty"`
 }
 
 type Chat struct {
@@ -14,15 +48,68 @@ type Chat struct {
 	UserID    int64     `json:"user_id"`
 	Title     *string   `json:"title"` // Nullable
 	CreatedAt time.Time `json:"created_at"`
+	// CurrentBranchID is the branch shown by default when the chat is
+	// opened; it moves to a new branch's ID whenever a message is edited.
+	CurrentBranchID *string `json:"current_branch_id"`
 }
 
 type Message struct {
 	ID               string    `json:"id"` // Using UUID for external ID
 	ChatID           string    `json:"chat_id"`
-	Sender           string    `json:"sender"` // "user" or "model"
+	Sender           string    `json:"sender"` // "user", "model", or "tool"
 	Content          string    `json:"content"`
 	Timestamp        time.Time `json:"timestamp"`
 	NegativeFeedback bool      `json:"negative_feedback"`
+	// ToolCall holds a JSON-encoded {name, args, result} object when
+	// Sender == "tool", recording an agent tool invocation in the transcript.
+	ToolCall *string `json:"tool_call,omitempty"`
+	// ParentMessageID links this message to the one it replied to or
+	// replaced, forming the conversation tree. Nil for the first message
+	// in a chat.
+	ParentMessageID *string `json:"parent_message_id,omitempty"`
+	// BranchID tags the lineage this message was created under. Editing a
+	// message starts a new BranchID for its sibling and everything built on
+	// top of it, while messages from before the fork keep their original
+	// BranchID.
+	BranchID string `json:"branch_id"`
+	// Partial is set on a model message that was persisted after its stream
+	// was cancelled (client disconnect, caller-aborted context) before the
+	// model finished generating, so callers know its Content is a prefix
+	// rather than a complete reply.
+	Partial bool `json:"partial,omitempty"`
+	// Deleted marks a soft-deleted message. The row (and its
+	// parent_message_id links) are kept so the conversation tree stays
+	// intact; every read path replaces Content with tombstoneContent
+	// instead of the original text.
+	Deleted bool `json:"deleted,omitempty"`
+	// ScheduledAt is set on a user message queued for delayed delivery
+	// instead of posted immediately. It has no ParentMessageID/BranchID yet
+	// at creation time; both are resolved when MessageScheduler promotes it,
+	// so it threads onto whatever branch is current at delivery time rather
+	// than the one current when it was scheduled. Nil for a normal message.
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+}
+
+// tombstoneContent replaces a deleted message's Content wherever it's read
+// back, so the transcript keeps its structure without exposing what was
+// deleted.
+const tombstoneContent = "[message deleted]"
+
+// applyTombstone replaces msg.Content with tombstoneContent if it's been
+// soft-deleted. Every store read path that can return a deleted message
+// calls this after scanning, so callers never see original deleted content.
+func applyTombstone(msg *Message) {
+	if msg.Deleted {
+		msg.Content = tombstoneContent
+	}
+}
+
+// Branch summarizes one fork of a chat's message tree, letting a UI render
+// a branch switcher without loading every message in every branch.
+type Branch struct {
+	BranchID      string `json:"branch_id"`
+	RootMessageID string `json:"root_message_id"`
+	TitleFragment string `json:"title_fragment"`
 }
 
 type DataChunk struct {
@@ -31,3 +118,10 @@ type DataChunk struct {
 	Embedding     []float32 `json:"-"` // Don't marshal to JSON response, internal
 	EmbeddingJSON string    `json:"-"` // Store as JSON string for DB
 }
+
+// ScoredChunk pairs a DataChunk with its similarity to some query embedding,
+// as returned by SearchChunks.
+type ScoredChunk struct {
+	Chunk      DataChunk
+	Similarity float32
+}
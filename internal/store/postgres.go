@@ -0,0 +1,1009 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq" // Postgres driver
+)
+
+// PostgresStore is the Store implementation for multi-instance deployments
+// that point a shared Postgres database at DatabaseURL instead of an
+// embedded SQLite file. It mirrors SQLiteStore method-for-method; the two
+// diverge only where the drivers themselves force it ($N placeholders,
+// RETURNING id instead of LastInsertId, GetBranches' query shape, and lexical
+// search, which uses a content_tsv column and ts_rank instead of an FTS5
+// table and bm25).
+type PostgresStore struct {
+	db *sql.DB
+
+	// vecIndex is the in-memory HNSW ANN index over data_chunks' embeddings,
+	// kept in sync with the table on ingest and persisted to
+	// data_chunks_vec_index so it doesn't have to be rebuilt from scratch on
+	// every restart. Same hnswIndex type SQLiteStore uses.
+	vecIndex *hnswIndex
+}
+
+func NewPostgresStore(dataSourceName string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", dataSourceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err = db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	store := &PostgresStore{db: db, vecIndex: newHNSWIndex()}
+	if err = runMigrations(db, "postgres"); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+	if err = store.loadVectorIndex(); err != nil {
+		log.Printf("Warning: failed to load persisted vector index, starting from an empty one: %v", err)
+	}
+	return store, nil
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+const userColumns = "id, external_user_id, password_hash, created_at, role, disabled, token_version"
+
+func scanUser(row interface{ Scan(...interface{}) error }, user *User) error {
+	return row.Scan(&user.ID, &user.ExternalUserID, &user.PasswordHash, &user.CreatedAt, &user.Role, &user.Disabled, &user.TokenVersion)
+}
+
+// User methods
+func (s *PostgresStore) GetUserByExternalID(externalUserID string) (*User, error) {
+	var user User
+	err := scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE external_user_id = $1", externalUserID), &user)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // User not found
+		}
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	return &user, nil
+}
+
+// CreateUser inserts a new user. The very first user ever created is
+// promoted to RoleAdmin (memos-style "host" bootstrapping); this isn't
+// wrapped in a transaction, so two signups racing to be first could in
+// theory both become admin, which is an acceptable edge case for a bootstrap
+// check that only ever matters once per deployment.
+func (s *PostgresStore) CreateUser(externalUserID, passwordHash string) (*User, error) {
+	var count int64
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count existing users: %w", err)
+	}
+	role := RoleUser
+	if count == 0 {
+		role = RoleAdmin
+	}
+
+	var id int64
+	err := s.db.QueryRow("INSERT INTO users (external_user_id, password_hash, role) VALUES ($1, $2, $3) RETURNING id", externalUserID, passwordHash, role).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert user: %w", err)
+	}
+	return s.GetUserByID(id)
+}
+
+func (s *PostgresStore) GetUserByID(id int64) (*User, error) {
+	var user User
+	err := scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1", id), &user)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // User not found
+		}
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	return &user, nil
+}
+
+// GetUsers returns up to limit users, newest first, starting after cursor,
+// for the admin user list. It follows the same (created_at, id) cursor
+// scheme as GetChatsByUserID.
+func (s *PostgresStore) GetUsers(limit int, cursor string) ([]User, string, error) {
+	cursorTime, cursorID, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	cursorIDInt, _ := strconv.ParseInt(cursorID, 10, 64)
+
+	query := `
+        SELECT ` + userColumns + `
+        FROM users
+        WHERE NOT $1 OR created_at < $2 OR (created_at = $2 AND id < $3)
+        ORDER BY created_at DESC, id DESC
+        LIMIT $4
+    `
+	hasCursor := cursor != ""
+	rows, err := s.db.Query(query, hasCursor, cursorTime, cursorIDInt, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, "", fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	nextCursor := ""
+	if len(users) > limit {
+		last := users[limit-1]
+		nextCursor = EncodeCursor(last.CreatedAt, strconv.FormatInt(last.ID, 10))
+		users = users[:limit]
+	}
+	return users, nextCursor, nil
+}
+
+func (s *PostgresStore) SetUserDisabled(userID int64, disabled bool) error {
+	res, err := s.db.Exec("UPDATE users SET disabled = $1 WHERE id = $2", disabled, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update user disabled flag: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+func (s *PostgresStore) SetUserPasswordHash(userID int64, passwordHash string) error {
+	res, err := s.db.Exec("UPDATE users SET password_hash = $1 WHERE id = $2", passwordHash, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update user password hash: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+func (s *PostgresStore) BumpUserTokenVersion(userID int64) error {
+	res, err := s.db.Exec("UPDATE users SET token_version = token_version + 1 WHERE id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to bump user token version: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+const sessionColumns = "id, user_id, refresh_token_hash, created_at, expires_at, revoked_at, user_agent, ip"
+
+func scanSession(row interface{ Scan(...interface{}) error }, session *Session) error {
+	return row.Scan(&session.ID, &session.UserID, &session.RefreshTokenHash, &session.CreatedAt, &session.ExpiresAt, &session.RevokedAt, &session.UserAgent, &session.IP)
+}
+
+// Session methods
+func (s *PostgresStore) CreateSession(userID int64, refreshTokenHash, userAgent, ip string, expiresAt time.Time) (*Session, error) {
+	id := uuid.NewString()
+	_, err := s.db.Exec(
+		"INSERT INTO sessions (id, user_id, refresh_token_hash, expires_at, user_agent, ip) VALUES ($1, $2, $3, $4, $5, $6)",
+		id, userID, refreshTokenHash, expiresAt, userAgent, ip,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert session: %w", err)
+	}
+	return s.GetSessionByID(id)
+}
+
+func (s *PostgresStore) GetSessionByID(sessionID string) (*Session, error) {
+	var session Session
+	err := scanSession(s.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = $1", sessionID), &session)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Session not found
+		}
+		return nil, fmt.Errorf("failed to query session: %w", err)
+	}
+	return &session, nil
+}
+
+func (s *PostgresStore) RotateSessionRefreshToken(sessionID, refreshTokenHash string, expiresAt time.Time) error {
+	res, err := s.db.Exec("UPDATE sessions SET refresh_token_hash = $1, expires_at = $2 WHERE id = $3", refreshTokenHash, expiresAt, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to rotate session refresh token: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+func (s *PostgresStore) RevokeSession(sessionID string) error {
+	res, err := s.db.Exec("UPDATE sessions SET revoked_at = now() WHERE id = $1", sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+func (s *PostgresStore) RevokeAllSessionsForUser(userID int64) error {
+	_, err := s.db.Exec("UPDATE sessions SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) GetSessionsByUserID(userID int64) ([]Session, error) {
+	rows, err := s.db.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		if err := scanSession(rows, &session); err != nil {
+			return nil, fmt.Errorf("failed to scan session row: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Chat methods
+func (s *PostgresStore) CreateChat(userID int64, title *string) (*Chat, error) {
+	chatID := uuid.NewString()
+	branchID := uuid.NewString() // first branch of the conversation tree
+
+	stmt, err := s.db.Prepare("INSERT INTO chats (id, user_id, title, created_at, current_branch_id) VALUES ($1, $2, $3, $4, $5)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare chat insert: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	_, err = stmt.Exec(chatID, userID, title, now, branchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute chat insert: %w", err)
+	}
+	return &Chat{ID: chatID, UserID: userID, Title: title, CreatedAt: now, CurrentBranchID: &branchID}, nil
+}
+
+func (s *PostgresStore) GetChatByID(chatID string, userID int64) (*Chat, error) {
+	var chat Chat
+	var title sql.NullString
+	var currentBranchID sql.NullString
+	err := s.db.QueryRow("SELECT id, user_id, title, created_at, current_branch_id FROM chats WHERE id = $1 AND user_id = $2", chatID, userID).
+		Scan(&chat.ID, &chat.UserID, &title, &chat.CreatedAt, &currentBranchID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+	if title.Valid {
+		chat.Title = &title.String
+	}
+	if currentBranchID.Valid {
+		chat.CurrentBranchID = &currentBranchID.String
+	}
+	return &chat, nil
+}
+
+// GetChatOwnerID returns the user_id of the chat owning chatID, so callers
+// that only have a chatID (e.g. MessageScheduler, working off MessagesDue)
+// can resolve the owner needed for ownership-scoped lookups like
+// GetChatByID.
+func (s *PostgresStore) GetChatOwnerID(chatID string) (int64, error) {
+	var userID int64
+	err := s.db.QueryRow("SELECT user_id FROM chats WHERE id = $1", chatID).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("chat not found")
+		}
+		return 0, fmt.Errorf("failed to get chat owner: %w", err)
+	}
+	return userID, nil
+}
+
+func (s *PostgresStore) GetChatsByUserID(userID int64, limit int, cursor string) ([]Chat, string, error) {
+	cursorTime, cursorID, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `
+        SELECT id, user_id, title, created_at, current_branch_id
+        FROM chats
+        WHERE user_id = $1 AND (NOT $2 OR created_at < $3 OR (created_at = $3 AND id < $4))
+        ORDER BY created_at DESC, id DESC
+        LIMIT $5
+    `
+	hasCursor := cursor != ""
+	rows, err := s.db.Query(query, userID, hasCursor, cursorTime, cursorID, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query chats: %w", err)
+	}
+	defer rows.Close()
+
+	var chats []Chat
+	for rows.Next() {
+		var chat Chat
+		var title sql.NullString
+		var currentBranchID sql.NullString
+		if err := rows.Scan(&chat.ID, &chat.UserID, &title, &chat.CreatedAt, &currentBranchID); err != nil {
+			return nil, "", fmt.Errorf("failed to scan chat row: %w", err)
+		}
+		if title.Valid {
+			chat.Title = &title.String
+		}
+		if currentBranchID.Valid {
+			chat.CurrentBranchID = &currentBranchID.String
+		}
+		chats = append(chats, chat)
+	}
+
+	nextCursor := ""
+	if len(chats) > limit {
+		last := chats[limit-1]
+		nextCursor = EncodeCursor(last.CreatedAt, last.ID)
+		chats = chats[:limit]
+	}
+	return chats, nextCursor, nil
+}
+
+// UpdateChatCurrentBranch moves a chat's default branch, e.g. after an edit
+// forks a new branch that should now be shown by default.
+func (s *PostgresStore) UpdateChatCurrentBranch(chatID string, userID int64, branchID string) error {
+	stmt, err := s.db.Prepare("UPDATE chats SET current_branch_id = $1 WHERE id = $2 AND user_id = $3")
+	if err != nil {
+		return fmt.Errorf("failed to prepare chat branch update: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(branchID, chatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to execute chat branch update: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("chat not found or not owned by user, branch not updated")
+	}
+	return nil
+}
+
+func (s *PostgresStore) UpdateChatTitle(chatID string, userID int64, title string) error {
+	stmt, err := s.db.Prepare("UPDATE chats SET title = $1 WHERE id = $2 AND user_id = $3")
+	if err != nil {
+		return fmt.Errorf("failed to prepare chat title update: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(title, chatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to execute chat title update: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("chat not found or not owned by user, title not updated")
+	}
+	return nil
+}
+
+// DeleteChat permanently removes chatID and every one of its messages,
+// regardless of owner. Unlike the user-facing DeleteMessage, this is a hard
+// delete: admins removing a chat want it gone, not tombstoned.
+func (s *PostgresStore) DeleteChat(chatID string) error {
+	if _, err := s.db.Exec("DELETE FROM messages WHERE chat_id = $1", chatID); err != nil {
+		return fmt.Errorf("failed to delete chat messages: %w", err)
+	}
+	res, err := s.db.Exec("DELETE FROM chats WHERE id = $1", chatID)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("chat not found")
+	}
+	return nil
+}
+
+// Message methods
+func (s *PostgresStore) CreateMessage(msg *Message) error {
+	msg.ID = uuid.NewString() // Ensure ID is set
+	msg.Timestamp = time.Now()
+
+	stmt, err := s.db.Prepare("INSERT INTO messages (id, chat_id, sender, content, timestamp, negative_feedback, tool_call, parent_message_id, branch_id, partial) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare message insert: %w", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(msg.ID, msg.ChatID, msg.Sender, msg.Content, msg.Timestamp, msg.NegativeFeedback, msg.ToolCall, msg.ParentMessageID, msg.BranchID, msg.Partial)
+	if err != nil {
+		return fmt.Errorf("failed to execute message insert: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) GetMessageByID(messageID string) (*Message, error) {
+	var msg Message
+	var toolCall sql.NullString
+	var parentMessageID sql.NullString
+	var scheduledAt sql.NullTime
+	query := "SELECT id, chat_id, sender, content, timestamp, negative_feedback, tool_call, parent_message_id, branch_id, partial, deleted, scheduled_at FROM messages WHERE id = $1"
+	err := s.db.QueryRow(query, messageID).Scan(&msg.ID, &msg.ChatID, &msg.Sender, &msg.Content, &msg.Timestamp, &msg.NegativeFeedback, &toolCall, &parentMessageID, &msg.BranchID, &msg.Partial, &msg.Deleted, &scheduledAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if toolCall.Valid {
+		msg.ToolCall = &toolCall.String
+	}
+	if parentMessageID.Valid {
+		msg.ParentMessageID = &parentMessageID.String
+	}
+	if scheduledAt.Valid {
+		msg.ScheduledAt = &scheduledAt.Time
+	}
+	applyTombstone(&msg)
+	return &msg, nil
+}
+
+func (s *PostgresStore) GetMessagesByChatID(chatID string, limit int, offset int, includeScheduled bool) ([]Message, error) {
+	query := "SELECT id, chat_id, sender, content, timestamp, negative_feedback, tool_call, parent_message_id, branch_id, partial, deleted, scheduled_at FROM messages WHERE chat_id = $1 AND ($2 OR scheduled_at IS NULL) ORDER BY timestamp ASC LIMIT $3 OFFSET $4"
+	rows, err := s.db.Query(query, chatID, includeScheduled, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var msg Message
+		var toolCall sql.NullString
+		var parentMessageID sql.NullString
+		var scheduledAt sql.NullTime
+		if err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Sender, &msg.Content, &msg.Timestamp, &msg.NegativeFeedback, &toolCall, &parentMessageID, &msg.BranchID, &msg.Partial, &msg.Deleted, &scheduledAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message row: %w", err)
+		}
+		if toolCall.Valid {
+			msg.ToolCall = &toolCall.String
+		}
+		if parentMessageID.Valid {
+			msg.ParentMessageID = &parentMessageID.String
+		}
+		if scheduledAt.Valid {
+			msg.ScheduledAt = &scheduledAt.Time
+		}
+		applyTombstone(&msg)
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func (s *PostgresStore) GetLastNMessagesByChatID(chatID string, n int) ([]Message, error) {
+	query := `
+        SELECT id, chat_id, sender, content, timestamp, negative_feedback, tool_call, parent_message_id, branch_id, partial, deleted, scheduled_at
+        FROM messages
+        WHERE chat_id = $1
+        ORDER BY timestamp DESC
+        LIMIT $2
+    `
+
+	rows, err := s.db.Query(query, chatID, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var msg Message
+		var toolCall sql.NullString
+		var parentMessageID sql.NullString
+		var scheduledAt sql.NullTime
+		if err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Sender, &msg.Content, &msg.Timestamp, &msg.NegativeFeedback, &toolCall, &parentMessageID, &msg.BranchID, &msg.Partial, &msg.Deleted, &scheduledAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message row: %w", err)
+		}
+		if toolCall.Valid {
+			msg.ToolCall = &toolCall.String
+		}
+		if parentMessageID.Valid {
+			msg.ParentMessageID = &parentMessageID.String
+		}
+		if scheduledAt.Valid {
+			msg.ScheduledAt = &scheduledAt.Time
+		}
+		applyTombstone(&msg)
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+// GetMessagesByBranch reconstructs the full linear transcript for a branch by
+// starting at its most recent message and following parent_message_id back
+// to the root. Messages created before the branch forked naturally carry an
+// earlier branch_id, but are still picked up by this walk. Scheduled
+// messages haven't been promoted into the tree yet, so they're excluded.
+func (s *PostgresStore) GetMessagesByBranch(chatID, branchID string) ([]Message, error) {
+	all, err := s.GetMessagesByChatID(chatID, allMessagesLimit, 0, false)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]Message, len(all))
+	var head *Message
+	for i := range all {
+		m := all[i]
+		byID[m.ID] = m
+		if m.BranchID == branchID && (head == nil || m.Timestamp.After(head.Timestamp)) {
+			h := m
+			head = &h
+		}
+	}
+	if head == nil {
+		return nil, nil // Branch not found, or has no messages
+	}
+
+	var chain []Message
+	for cur := head; cur != nil; {
+		chain = append(chain, *cur)
+		if cur.ParentMessageID == nil {
+			break
+		}
+		parent, ok := byID[*cur.ParentMessageID]
+		if !ok {
+			break
+		}
+		cur = &parent
+	}
+
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// GetLastNMessagesByBranch returns up to the last n messages of a branch's
+// reconstructed transcript, in chronological order.
+func (s *PostgresStore) GetLastNMessagesByBranch(chatID, branchID string, n int) ([]Message, error) {
+	chain, err := s.GetMessagesByBranch(chatID, branchID)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) > n {
+		chain = chain[len(chain)-n:]
+	}
+	return chain, nil
+}
+
+// GetBranches lists every branch of a chat's message tree, identified by the
+// earliest message created under each branch_id, for a lightweight UI branch
+// switcher. Unlike SQLite, Postgres doesn't hand bare SELECT columns the
+// values from the row that produced a MIN()/MAX() aggregate, so this uses
+// DISTINCT ON to pick each branch's earliest row directly.
+func (s *PostgresStore) GetBranches(chatID string) ([]Branch, error) {
+	// DISTINCT ON picks, per branch_id, the first row in the inner ORDER BY
+	// (its earliest message); the outer query then re-sorts those one-per-branch
+	// rows by that same timestamp so branches come back oldest-first overall.
+	query := `
+        SELECT branch_id, id, content, first_ts
+        FROM (
+            SELECT DISTINCT ON (branch_id) branch_id, id, content, timestamp AS first_ts
+            FROM messages
+            WHERE chat_id = $1
+            ORDER BY branch_id, timestamp ASC
+        ) branch_roots
+        ORDER BY first_ts ASC
+    `
+	rows, err := s.db.Query(query, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query branches: %w", err)
+	}
+	defer rows.Close()
+
+	var branches []Branch
+	for rows.Next() {
+		var b Branch
+		var firstTS time.Time
+		if err := rows.Scan(&b.BranchID, &b.RootMessageID, &b.TitleFragment, &firstTS); err != nil {
+			return nil, fmt.Errorf("failed to scan branch row: %w", err)
+		}
+		if len(b.TitleFragment) > branchTitleFragmentLen {
+			b.TitleFragment = b.TitleFragment[:branchTitleFragmentLen] + "..."
+		}
+		branches = append(branches, b)
+	}
+	return branches, nil
+}
+
+func (s *PostgresStore) UpdateMessageFeedback(messageID string, negativeFeedback bool) error {
+	stmt, err := s.db.Prepare("UPDATE messages SET negative_feedback = $1 WHERE id = $2")
+	if err != nil {
+		return fmt.Errorf("failed to prepare feedback update: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(negativeFeedback, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to execute feedback update: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("message not found, feedback not updated")
+	}
+	return nil
+}
+
+// DeleteMessage soft-deletes messageID, scoped to chats owned by userID so a
+// message can't be deleted by anyone other than its chat's owner. The row
+// itself (and its parent_message_id link) is kept; GetMessageByID and the
+// GetMessagesBy* readers substitute tombstone content for it going forward.
+func (s *PostgresStore) DeleteMessage(messageID string, userID int64) error {
+	stmt, err := s.db.Prepare(`
+        UPDATE messages
+        SET deleted = TRUE
+        WHERE id = $1 AND chat_id IN (SELECT id FROM chats WHERE user_id = $2)
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to prepare message delete: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(messageID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to execute message delete: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("message not found or not owned by user")
+	}
+	return nil
+}
+
+// CreateScheduledMessage stores msg for delivery at deliverAt. msg has no
+// ParentMessageID/BranchID yet; MessageScheduler resolves both once it's
+// actually promoted, so the message threads onto whatever branch is current
+// at delivery time rather than the one current when it was scheduled.
+func (s *PostgresStore) CreateScheduledMessage(msg *Message, deliverAt time.Time) error {
+	msg.ID = uuid.NewString()
+	msg.Timestamp = time.Now()
+	msg.ScheduledAt = &deliverAt
+
+	stmt, err := s.db.Prepare("INSERT INTO messages (id, chat_id, sender, content, timestamp, negative_feedback, scheduled_at) VALUES ($1, $2, $3, $4, $5, $6, $7)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare scheduled message insert: %w", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(msg.ID, msg.ChatID, msg.Sender, msg.Content, msg.Timestamp, msg.NegativeFeedback, deliverAt)
+	if err != nil {
+		return fmt.Errorf("failed to execute scheduled message insert: %w", err)
+	}
+	return nil
+}
+
+// MessagesDue returns every scheduled message whose ScheduledAt is at or
+// before now, across all chats, for MessageScheduler to promote.
+func (s *PostgresStore) MessagesDue(now time.Time) ([]Message, error) {
+	query := "SELECT id, chat_id, sender, content, timestamp, negative_feedback, tool_call, parent_message_id, branch_id, partial, deleted, scheduled_at FROM messages WHERE scheduled_at IS NOT NULL AND scheduled_at <= $1 ORDER BY scheduled_at ASC"
+	rows, err := s.db.Query(query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var msg Message
+		var toolCall sql.NullString
+		var parentMessageID sql.NullString
+		var scheduledAt sql.NullTime
+		if err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Sender, &msg.Content, &msg.Timestamp, &msg.NegativeFeedback, &toolCall, &parentMessageID, &msg.BranchID, &msg.Partial, &msg.Deleted, &scheduledAt); err != nil {
+			return nil, fmt.Errorf("failed to scan due message row: %w", err)
+		}
+		if toolCall.Valid {
+			msg.ToolCall = &toolCall.String
+		}
+		if parentMessageID.Valid {
+			msg.ParentMessageID = &parentMessageID.String
+		}
+		if scheduledAt.Valid {
+			msg.ScheduledAt = &scheduledAt.Time
+		}
+		applyTombstone(&msg)
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// CancelScheduledMessage deletes a scheduled message outright, scoped to
+// chats owned by userID. A scheduled message never entered the conversation
+// tree, so unlike DeleteMessage this removes the row rather than tombstoning
+// it.
+func (s *PostgresStore) CancelScheduledMessage(messageID string, userID int64) error {
+	stmt, err := s.db.Prepare(`
+        DELETE FROM messages
+        WHERE id = $1 AND scheduled_at IS NOT NULL AND chat_id IN (SELECT id FROM chats WHERE user_id = $2)
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to prepare scheduled message delete: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(messageID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to execute scheduled message delete: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("scheduled message not found or not owned by user")
+	}
+	return nil
+}
+
+// PromoteScheduledMessage threads a scheduled message onto parentMessageID
+// and branchID and clears its ScheduledAt, so it joins the conversation tree
+// as a live message.
+func (s *PostgresStore) PromoteScheduledMessage(messageID string, parentMessageID *string, branchID string) error {
+	stmt, err := s.db.Prepare("UPDATE messages SET parent_message_id = $1, branch_id = $2, scheduled_at = NULL WHERE id = $3")
+	if err != nil {
+		return fmt.Errorf("failed to prepare scheduled message promotion: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(parentMessageID, branchID, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to execute scheduled message promotion: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("scheduled message not found")
+	}
+	return nil
+}
+
+// DataChunk methods (for RAG)
+func (s *PostgresStore) createDataChunk(chunk *DataChunk) error {
+	embeddingBytes, err := json.Marshal(chunk.Embedding)
+	if err != nil {
+		return fmt.Errorf("failed to marshal embedding: %w", err)
+	}
+	chunk.EmbeddingJSON = string(embeddingBytes)
+
+	err = s.db.QueryRow(
+		"INSERT INTO data_chunks (content, embedding_json, content_tsv) VALUES ($1, $2, to_tsvector('english', $1)) RETURNING id",
+		chunk.Content, chunk.EmbeddingJSON,
+	).Scan(&chunk.ID)
+	if err != nil {
+		return fmt.Errorf("failed to execute data_chunk insert: %w", err)
+	}
+
+	if len(chunk.Embedding) > 0 {
+		s.vecIndex.Insert(chunk.ID, chunk.Embedding)
+	}
+	return nil
+}
+
+// getDataChunkByID fetches a single chunk's content by ID, for resolving the
+// hits SearchChunks gets back from the vector index.
+func (s *PostgresStore) getDataChunkByID(id int64) (*DataChunk, error) {
+	var chunk DataChunk
+	err := s.db.QueryRow("SELECT id, content FROM data_chunks WHERE id = $1", id).Scan(&chunk.ID, &chunk.Content)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get data_chunk %d: %w", id, err)
+	}
+	return &chunk, nil
+}
+
+// SearchChunks returns up to k data chunks whose embedding is most similar to
+// queryEmbedding, using the in-memory HNSW index rather than scanning every
+// row. Chunks scoring below minScore are dropped.
+func (s *PostgresStore) SearchChunks(queryEmbedding []float32, k int, minScore float32) ([]ScoredChunk, error) {
+	hits := s.vecIndex.Search(queryEmbedding, k, hnswDefaultEf)
+
+	scored := make([]ScoredChunk, 0, len(hits))
+	for _, hit := range hits {
+		similarity := 1 - hit.dist
+		if similarity < minScore {
+			continue
+		}
+		chunk, err := s.getDataChunkByID(hit.id)
+		if err != nil {
+			return nil, err
+		}
+		if chunk == nil {
+			continue // stale index entry for a since-deleted chunk
+		}
+		scored = append(scored, ScoredChunk{Chunk: *chunk, Similarity: similarity})
+	}
+	return scored, nil
+}
+
+// SearchChunksBM25 returns up to k data chunks ranked by lexical match
+// against query, using the content_tsv GIN index rather than the HNSW
+// index. Postgres has no FTS5/BM25 built in, so ts_rank over a tsvector
+// column stands in for it; the absolute scores aren't comparable to
+// SQLite's, but callers only need the resulting ranking to feed into
+// Reciprocal Rank Fusion alongside SearchChunks.
+func (s *PostgresStore) SearchChunksBM25(query string, k int) ([]ScoredChunk, error) {
+	rows, err := s.db.Query(`
+		SELECT id, content, ts_rank(content_tsv, plainto_tsquery('english', $1)) AS score
+		FROM data_chunks
+		WHERE content_tsv @@ plainto_tsquery('english', $1)
+		ORDER BY score DESC
+		LIMIT $2
+	`, query, k)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run full-text search: %w", err)
+	}
+	defer rows.Close()
+
+	var scored []ScoredChunk
+	for rows.Next() {
+		var chunk DataChunk
+		var score float64
+		if err := rows.Scan(&chunk.ID, &chunk.Content, &score); err != nil {
+			return nil, fmt.Errorf("failed to scan full-text search row: %w", err)
+		}
+		scored = append(scored, ScoredChunk{Chunk: chunk, Similarity: float32(score)})
+	}
+	return scored, rows.Err()
+}
+
+// VectorIndexSize reports how many chunks are currently indexed for ANN
+// search, mainly so callers can log a useful warning on an empty index.
+func (s *PostgresStore) VectorIndexSize() int {
+	s.vecIndex.mu.RLock()
+	defer s.vecIndex.mu.RUnlock()
+	return len(s.vecIndex.nodes)
+}
+
+// saveVectorIndex persists the full in-memory HNSW graph as a single JSON
+// blob, so it doesn't need to be rebuilt chunk-by-chunk on the next restart.
+func (s *PostgresStore) saveVectorIndex() error {
+	s.vecIndex.mu.RLock()
+	persisted := hnswPersisted{
+		Nodes:      s.vecIndex.nodes,
+		EntryPoint: s.vecIndex.entryPoint,
+		MaxLevel:   s.vecIndex.maxLevel,
+	}
+	s.vecIndex.mu.RUnlock()
+
+	data, err := json.Marshal(persisted)
+	if err != nil {
+		return fmt.Errorf("failed to marshal vector index: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO data_chunks_vec_index (id, graph_json) VALUES (1, $1)
+		ON CONFLICT (id) DO UPDATE SET graph_json = excluded.graph_json
+	`, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to persist vector index: %w", err)
+	}
+	return nil
+}
+
+// loadVectorIndex restores the HNSW graph persisted by saveVectorIndex, if
+// any. A missing sidecar row just means ingestion hasn't run yet; the index
+// is built fresh on the next IngestDataFromFile.
+func (s *PostgresStore) loadVectorIndex() error {
+	var graphJSON string
+	err := s.db.QueryRow("SELECT graph_json FROM data_chunks_vec_index WHERE id = 1").Scan(&graphJSON)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load vector index: %w", err)
+	}
+
+	var persisted hnswPersisted
+	if err := json.Unmarshal([]byte(graphJSON), &persisted); err != nil {
+		return fmt.Errorf("failed to unmarshal vector index: %w", err)
+	}
+
+	idx := newHNSWIndex()
+	idx.nodes = persisted.Nodes
+	idx.entryPoint = persisted.EntryPoint
+	idx.maxLevel = persisted.MaxLevel
+	s.vecIndex = idx
+	return nil
+}
+
+func (s *PostgresStore) GetAllDataChunks() ([]DataChunk, error) {
+	rows, err := s.db.Query("SELECT id, content, embedding_json FROM data_chunks")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query data_chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []DataChunk
+	for rows.Next() {
+		var chunk DataChunk
+		var embeddingJSON string // Read as string from DB
+		if err := rows.Scan(&chunk.ID, &chunk.Content, &embeddingJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan data_chunk row: %w", err)
+		}
+		if embeddingJSON != "" {
+			if err := json.Unmarshal([]byte(embeddingJSON), &chunk.Embedding); err != nil {
+				log.Printf("Warning: failed to unmarshal embedding for chunk %d (content: %.50s...): %v. Embedding will be empty.", chunk.ID, chunk.Content, err)
+				chunk.Embedding = nil // Explicitly set to nil if unmarshal fails
+			}
+		} else {
+			log.Printf("Warning: empty embedding_json for chunk ID %d. Embedding will be empty.", chunk.ID)
+			chunk.Embedding = nil // Ensure it's nil if the DB field was empty/NULL
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// GetRandomDataChunks samples up to k data chunks uniformly at random, for
+// features like prompt-starter generation that just need a representative
+// slice of the corpus rather than a similarity search.
+func (s *PostgresStore) GetRandomDataChunks(k int) ([]DataChunk, error) {
+	rows, err := s.db.Query("SELECT id, content, embedding_json FROM data_chunks ORDER BY RANDOM() LIMIT $1", k)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query random data_chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []DataChunk
+	for rows.Next() {
+		var chunk DataChunk
+		var embeddingJSON string
+		if err := rows.Scan(&chunk.ID, &chunk.Content, &embeddingJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan data_chunk row: %w", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+func (s *PostgresStore) ClearDataChunks() error {
+	_, err := s.db.Exec("DELETE FROM data_chunks")
+	if err != nil {
+		return fmt.Errorf("failed to delete data_chunks: %w", err)
+	}
+
+	s.vecIndex = newHNSWIndex()
+	if _, err := s.db.Exec("DELETE FROM data_chunks_vec_index"); err != nil {
+		log.Printf("Warning: could not clear persisted vector index: %v", err)
+	}
+	return nil
+}
+
+// IngestDataFromFile reads data.md, extracts text, generates embeddings, and stores them.
+func (s *PostgresStore) IngestDataFromFile(filePath string, embedder func(string) ([]float32, error)) (int, error) {
+	rawChunks, err := readDataFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+	if len(rawChunks) == 0 {
+		return 0, nil
+	}
+	return ingestChunks(rawChunks, embedder, s.ClearDataChunks, s.createDataChunk, s.saveVectorIndex)
+}
@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,8 +13,22 @@ import (
 	_ "github.com/mattn/go-sqlite3" // SQLite driver
 )
 
+// allMessagesLimit bounds the query used to reconstruct a branch's full
+// message chain; conversations are expected to stay well under this.
+const allMessagesLimit = 10000
+
+// branchTitleFragmentLen caps how much of a branch's root message is kept in
+// its title_fragment, for a compact branch index.
+const branchTitleFragmentLen = 60
+
 type SQLiteStore struct {
 	db *sql.DB
+
+	// vecIndex is the in-memory HNSW ANN index over data_chunks' embeddings,
+	// kept in sync with the table on ingest and persisted to
+	// data_chunks_vec_index so it doesn't have to be rebuilt from scratch
+	// (which would mean re-walking every chunk) on every restart.
+	vecIndex *hnswIndex
 }
 
 func NewSQLiteStore(dataSourceName string) (*SQLiteStore, error) {
@@ -26,9 +40,12 @@ func NewSQLiteStore(dataSourceName string) (*SQLiteStore, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	store := &SQLiteStore{db: db}
-	if err = store.initSchema(); err != nil {
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	store := &SQLiteStore{db: db, vecIndex: newHNSWIndex()}
+	if err = runMigrations(db, "sqlite"); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+	if err = store.loadVectorIndex(); err != nil {
+		log.Printf("Warning: failed to load persisted vector index, starting from an empty one: %v", err)
 	}
 	return store, nil
 }
@@ -37,47 +54,16 @@ func (s *SQLiteStore) Close() error {
 	return s.db.Close()
 }
 
-func (s *SQLiteStore) initSchema() error {
-	schema := `
-    CREATE TABLE IF NOT EXISTS users (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        external_user_id TEXT UNIQUE NOT NULL,
-        password_hash TEXT NOT NULL,
-        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-    );
-
-    CREATE TABLE IF NOT EXISTS chats (
-        id TEXT PRIMARY KEY, -- UUID
-        user_id INTEGER NOT NULL,
-        title TEXT,
-        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-        FOREIGN KEY (user_id) REFERENCES users (id)
-    );
-
-    CREATE TABLE IF NOT EXISTS messages (
-        id TEXT PRIMARY KEY, -- UUID
-        chat_id TEXT NOT NULL,
-        sender TEXT NOT NULL CHECK (sender IN ('user', 'model')),
-        content TEXT NOT NULL,
-        timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-        negative_feedback BOOLEAN DEFAULT FALSE,
-        FOREIGN KEY (chat_id) REFERENCES chats (id)
-    );
-
-    CREATE TABLE IF NOT EXISTS data_chunks (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        content TEXT NOT NULL,
-        embedding_json TEXT -- Storing as JSON string of []float32
-    );
-    `
-	_, err := s.db.Exec(schema)
-	return err
+const userColumns = "id, external_user_id, password_hash, created_at, role, disabled, token_version"
+
+func scanUser(row interface{ Scan(...interface{}) error }, user *User) error {
+	return row.Scan(&user.ID, &user.ExternalUserID, &user.PasswordHash, &user.CreatedAt, &user.Role, &user.Disabled, &user.TokenVersion)
 }
 
 // User methods
 func (s *SQLiteStore) GetUserByExternalID(externalUserID string) (*User, error) {
 	var user User
-	err := s.db.QueryRow("SELECT id, external_user_id, password_hash, created_at FROM users WHERE external_user_id = ?", externalUserID).Scan(&user.ID, &user.ExternalUserID, &user.PasswordHash, &user.CreatedAt)
+	err := scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE external_user_id = ?", externalUserID), &user)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // User not found
@@ -87,45 +73,225 @@ func (s *SQLiteStore) GetUserByExternalID(externalUserID string) (*User, error)
 	return &user, nil
 }
 
+// CreateUser inserts a new user. The very first user ever created is
+// promoted to RoleAdmin (memos-style "host" bootstrapping); this isn't
+// wrapped in a transaction, so two signups racing to be first could in
+// theory both become admin, which is an acceptable edge case for a bootstrap
+// check that only ever matters once per deployment.
 func (s *SQLiteStore) CreateUser(externalUserID, passwordHash string) (*User, error) {
-	res, err := s.db.Exec("INSERT INTO users (external_user_id, password_hash) VALUES (?, ?)", externalUserID, passwordHash)
+	var count int64
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count existing users: %w", err)
+	}
+	role := RoleUser
+	if count == 0 {
+		role = RoleAdmin
+	}
+
+	res, err := s.db.Exec("INSERT INTO users (external_user_id, password_hash, role) VALUES (?, ?, ?)", externalUserID, passwordHash, role)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert user: %w", err)
 	}
 	id, _ := res.LastInsertId()
-	return s.getUserByID(id)
+	return s.GetUserByID(id)
 }
 
-func (s *SQLiteStore) getUserByID(id int64) (*User, error) {
+func (s *SQLiteStore) GetUserByID(id int64) (*User, error) {
 	var user User
-	err := s.db.QueryRow("SELECT id, external_user_id, password_hash, created_at FROM users WHERE id = ?", id).Scan(&user.ID, &user.ExternalUserID, &user.PasswordHash, &user.CreatedAt)
+	err := scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id), &user)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // User not found
+		}
 		return nil, fmt.Errorf("failed to get user by id: %w", err)
 	}
 	return &user, nil
 }
 
+// GetUsers returns up to limit users, newest first, starting after cursor,
+// for the admin user list. It follows the same (created_at, id) cursor
+// scheme as GetChatsByUserID.
+func (s *SQLiteStore) GetUsers(limit int, cursor string) ([]User, string, error) {
+	cursorTime, cursorID, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	cursorIDInt, _ := strconv.ParseInt(cursorID, 10, 64)
+
+	query := `
+        SELECT ` + userColumns + `
+        FROM users
+        WHERE NOT ? OR created_at < ? OR (created_at = ? AND id < ?)
+        ORDER BY created_at DESC, id DESC
+        LIMIT ?
+    `
+	hasCursor := cursor != ""
+	rows, err := s.db.Query(query, hasCursor, cursorTime, cursorTime, cursorIDInt, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, "", fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	nextCursor := ""
+	if len(users) > limit {
+		last := users[limit-1]
+		nextCursor = EncodeCursor(last.CreatedAt, strconv.FormatInt(last.ID, 10))
+		users = users[:limit]
+	}
+	return users, nextCursor, nil
+}
+
+func (s *SQLiteStore) SetUserDisabled(userID int64, disabled bool) error {
+	res, err := s.db.Exec("UPDATE users SET disabled = ? WHERE id = ?", disabled, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update user disabled flag: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+func (s *SQLiteStore) SetUserPasswordHash(userID int64, passwordHash string) error {
+	res, err := s.db.Exec("UPDATE users SET password_hash = ? WHERE id = ?", passwordHash, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update user password hash: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+func (s *SQLiteStore) BumpUserTokenVersion(userID int64) error {
+	res, err := s.db.Exec("UPDATE users SET token_version = token_version + 1 WHERE id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("failed to bump user token version: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+const sessionColumns = "id, user_id, refresh_token_hash, created_at, expires_at, revoked_at, user_agent, ip"
+
+func scanSession(row interface{ Scan(...interface{}) error }, session *Session) error {
+	return row.Scan(&session.ID, &session.UserID, &session.RefreshTokenHash, &session.CreatedAt, &session.ExpiresAt, &session.RevokedAt, &session.UserAgent, &session.IP)
+}
+
+// Session methods
+func (s *SQLiteStore) CreateSession(userID int64, refreshTokenHash, userAgent, ip string, expiresAt time.Time) (*Session, error) {
+	id := uuid.NewString()
+	_, err := s.db.Exec(
+		"INSERT INTO sessions (id, user_id, refresh_token_hash, expires_at, user_agent, ip) VALUES (?, ?, ?, ?, ?, ?)",
+		id, userID, refreshTokenHash, expiresAt, userAgent, ip,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert session: %w", err)
+	}
+	return s.GetSessionByID(id)
+}
+
+func (s *SQLiteStore) GetSessionByID(sessionID string) (*Session, error) {
+	var session Session
+	err := scanSession(s.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = ?", sessionID), &session)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Session not found
+		}
+		return nil, fmt.Errorf("failed to query session: %w", err)
+	}
+	return &session, nil
+}
+
+func (s *SQLiteStore) RotateSessionRefreshToken(sessionID, refreshTokenHash string, expiresAt time.Time) error {
+	res, err := s.db.Exec("UPDATE sessions SET refresh_token_hash = ?, expires_at = ? WHERE id = ?", refreshTokenHash, expiresAt, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to rotate session refresh token: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+func (s *SQLiteStore) RevokeSession(sessionID string) error {
+	res, err := s.db.Exec("UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = ?", sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+func (s *SQLiteStore) RevokeAllSessionsForUser(userID int64) error {
+	_, err := s.db.Exec("UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL", userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetSessionsByUserID(userID int64) ([]Session, error) {
+	rows, err := s.db.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		if err := scanSession(rows, &session); err != nil {
+			return nil, fmt.Errorf("failed to scan session row: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
 // Chat methods
 func (s *SQLiteStore) CreateChat(userID int64, title *string) (*Chat, error) {
 	chatID := uuid.NewString()
-	stmt, err := s.db.Prepare("INSERT INTO chats (id, user_id, title, created_at) VALUES (?, ?, ?, ?)")
+	branchID := uuid.NewString() // first branch of the conversation tree
+
+	stmt, err := s.db.Prepare("INSERT INTO chats (id, user_id, title, created_at, current_branch_id) VALUES (?, ?, ?, ?, ?)")
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare chat insert: %w", err)
 	}
 	defer stmt.Close()
 
 	now := time.Now()
-	_, err = stmt.Exec(chatID, userID, title, now)
+	_, err = stmt.Exec(chatID, userID, title, now, branchID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute chat insert: %w", err)
 	}
-	return &Chat{ID: chatID, UserID: userID, Title: title, CreatedAt: now}, nil
+	return &Chat{ID: chatID, UserID: userID, Title: title, CreatedAt: now, CurrentBranchID: &branchID}, nil
 }
 
 func (s *SQLiteStore) GetChatByID(chatID string, userID int64) (*Chat, error) {
 	var chat Chat
 	var title sql.NullString
-	err := s.db.QueryRow("SELECT id, user_id, title, created_at FROM chats WHERE id = ? AND user_id = ?", chatID, userID).Scan(&chat.ID, &chat.UserID, &title, &chat.CreatedAt)
+	var currentBranchID sql.NullString
+	err := s.db.QueryRow("SELECT id, user_id, title, created_at, current_branch_id FROM chats WHERE id = ? AND user_id = ?", chatID, userID).
+		Scan(&chat.ID, &chat.UserID, &title, &chat.CreatedAt, &currentBranchID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Not found
@@ -135,13 +301,45 @@ func (s *SQLiteStore) GetChatByID(chatID string, userID int64) (*Chat, error) {
 	if title.Valid {
 		chat.Title = &title.String
 	}
+	if currentBranchID.Valid {
+		chat.CurrentBranchID = &currentBranchID.String
+	}
 	return &chat, nil
 }
 
-func (s *SQLiteStore) GetChatsByUserID(userID int64) ([]Chat, error) {
-	rows, err := s.db.Query("SELECT id, user_id, title, created_at FROM chats WHERE user_id = ? ORDER BY created_at DESC", userID)
+// GetChatOwnerID returns the user_id of the chat owning chatID, so callers
+// that only have a chatID (e.g. MessageScheduler, working off MessagesDue)
+// can resolve the owner needed for ownership-scoped lookups like
+// GetChatByID.
+func (s *SQLiteStore) GetChatOwnerID(chatID string) (int64, error) {
+	var userID int64
+	err := s.db.QueryRow("SELECT user_id FROM chats WHERE id = ?", chatID).Scan(&userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query chats: %w", err)
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("chat not found")
+		}
+		return 0, fmt.Errorf("failed to get chat owner: %w", err)
+	}
+	return userID, nil
+}
+
+func (s *SQLiteStore) GetChatsByUserID(userID int64, limit int, cursor string) ([]Chat, string, error) {
+	cursorTime, cursorID, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `
+        SELECT id, user_id, title, created_at, current_branch_id
+        FROM chats
+        WHERE user_id = ? AND (NOT ? OR created_at < ? OR (created_at = ? AND id < ?))
+        ORDER BY created_at DESC, id DESC
+        LIMIT ?
+    `
+	hasCursor := cursor != ""
+	rows, err := s.db.Query(query, userID, hasCursor, cursorTime, cursorTime, cursorID, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query chats: %w", err)
 	}
 	defer rows.Close()
 
@@ -149,15 +347,46 @@ func (s *SQLiteStore) GetChatsByUserID(userID int64) ([]Chat, error) {
 	for rows.Next() {
 		var chat Chat
 		var title sql.NullString
-		if err := rows.Scan(&chat.ID, &chat.UserID, &title, &chat.CreatedAt); err != nil {
-			return nil, fmt.Errorf("failed to scan chat row: %w", err)
+		var currentBranchID sql.NullString
+		if err := rows.Scan(&chat.ID, &chat.UserID, &title, &chat.CreatedAt, &currentBranchID); err != nil {
+			return nil, "", fmt.Errorf("failed to scan chat row: %w", err)
 		}
 		if title.Valid {
 			chat.Title = &title.String
 		}
+		if currentBranchID.Valid {
+			chat.CurrentBranchID = &currentBranchID.String
+		}
 		chats = append(chats, chat)
 	}
-	return chats, nil
+
+	nextCursor := ""
+	if len(chats) > limit {
+		last := chats[limit-1]
+		nextCursor = EncodeCursor(last.CreatedAt, last.ID)
+		chats = chats[:limit]
+	}
+	return chats, nextCursor, nil
+}
+
+// UpdateChatCurrentBranch moves a chat's default branch, e.g. after an edit
+// forks a new branch that should now be shown by default.
+func (s *SQLiteStore) UpdateChatCurrentBranch(chatID string, userID int64, branchID string) error {
+	stmt, err := s.db.Prepare("UPDATE chats SET current_branch_id = ? WHERE id = ? AND user_id = ?")
+	if err != nil {
+		return fmt.Errorf("failed to prepare chat branch update: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(branchID, chatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to execute chat branch update: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("chat not found or not owned by user, branch not updated")
+	}
+	return nil
 }
 
 func (s *SQLiteStore) UpdateChatTitle(chatID string, userID int64, title string) error {
@@ -178,27 +407,71 @@ func (s *SQLiteStore) UpdateChatTitle(chatID string, userID int64, title string)
 	return nil
 }
 
+// DeleteChat permanently removes chatID and every one of its messages,
+// regardless of owner. Unlike the user-facing DeleteMessage, this is a hard
+// delete: admins removing a chat want it gone, not tombstoned.
+func (s *SQLiteStore) DeleteChat(chatID string) error {
+	if _, err := s.db.Exec("DELETE FROM messages WHERE chat_id = ?", chatID); err != nil {
+		return fmt.Errorf("failed to delete chat messages: %w", err)
+	}
+	res, err := s.db.Exec("DELETE FROM chats WHERE id = ?", chatID)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("chat not found")
+	}
+	return nil
+}
+
 // Message methods
 func (s *SQLiteStore) CreateMessage(msg *Message) error {
 	msg.ID = uuid.NewString() // Ensure ID is set
 	msg.Timestamp = time.Now()
 
-	stmt, err := s.db.Prepare("INSERT INTO messages (id, chat_id, sender, content, timestamp, negative_feedback) VALUES (?, ?, ?, ?, ?, ?)")
+	stmt, err := s.db.Prepare("INSERT INTO messages (id, chat_id, sender, content, timestamp, negative_feedback, tool_call, parent_message_id, branch_id, partial) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return fmt.Errorf("failed to prepare message insert: %w", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(msg.ID, msg.ChatID, msg.Sender, msg.Content, msg.Timestamp, msg.NegativeFeedback)
+	_, err = stmt.Exec(msg.ID, msg.ChatID, msg.Sender, msg.Content, msg.Timestamp, msg.NegativeFeedback, msg.ToolCall, msg.ParentMessageID, msg.BranchID, msg.Partial)
 	if err != nil {
 		return fmt.Errorf("failed to execute message insert: %w", err)
 	}
 	return nil
 }
 
-func (s *SQLiteStore) GetMessagesByChatID(chatID string, limit int, offset int) ([]Message, error) {
-	query := "SELECT id, chat_id, sender, content, timestamp, negative_feedback FROM messages WHERE chat_id = ? ORDER BY timestamp ASC LIMIT ? OFFSET ?"
-	rows, err := s.db.Query(query, chatID, limit, offset)
+func (s *SQLiteStore) GetMessageByID(messageID string) (*Message, error) {
+	var msg Message
+	var toolCall sql.NullString
+	var parentMessageID sql.NullString
+	var scheduledAt sql.NullTime
+	query := "SELECT id, chat_id, sender, content, timestamp, negative_feedback, tool_call, parent_message_id, branch_id, partial, deleted, scheduled_at FROM messages WHERE id = ?"
+	err := s.db.QueryRow(query, messageID).Scan(&msg.ID, &msg.ChatID, &msg.Sender, &msg.Content, &msg.Timestamp, &msg.NegativeFeedback, &toolCall, &parentMessageID, &msg.BranchID, &msg.Partial, &msg.Deleted, &scheduledAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if toolCall.Valid {
+		msg.ToolCall = &toolCall.String
+	}
+	if parentMessageID.Valid {
+		msg.ParentMessageID = &parentMessageID.String
+	}
+	if scheduledAt.Valid {
+		msg.ScheduledAt = &scheduledAt.Time
+	}
+	applyTombstone(&msg)
+	return &msg, nil
+}
+
+func (s *SQLiteStore) GetMessagesByChatID(chatID string, limit int, offset int, includeScheduled bool) ([]Message, error) {
+	query := "SELECT id, chat_id, sender, content, timestamp, negative_feedback, tool_call, parent_message_id, branch_id, partial, deleted, scheduled_at FROM messages WHERE chat_id = ? AND (? OR scheduled_at IS NULL) ORDER BY timestamp ASC LIMIT ? OFFSET ?"
+	rows, err := s.db.Query(query, chatID, includeScheduled, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query messages: %w", err)
 	}
@@ -207,9 +480,22 @@ func (s *SQLiteStore) GetMessagesByChatID(chatID string, limit int, offset int)
 	var messages []Message
 	for rows.Next() {
 		var msg Message
-		if err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Sender, &msg.Content, &msg.Timestamp, &msg.NegativeFeedback); err != nil {
+		var toolCall sql.NullString
+		var parentMessageID sql.NullString
+		var scheduledAt sql.NullTime
+		if err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Sender, &msg.Content, &msg.Timestamp, &msg.NegativeFeedback, &toolCall, &parentMessageID, &msg.BranchID, &msg.Partial, &msg.Deleted, &scheduledAt); err != nil {
 			return nil, fmt.Errorf("failed to scan message row: %w", err)
 		}
+		if toolCall.Valid {
+			msg.ToolCall = &toolCall.String
+		}
+		if parentMessageID.Valid {
+			msg.ParentMessageID = &parentMessageID.String
+		}
+		if scheduledAt.Valid {
+			msg.ScheduledAt = &scheduledAt.Time
+		}
+		applyTombstone(&msg)
 		messages = append(messages, msg)
 	}
 	return messages, nil
@@ -217,7 +503,7 @@ func (s *SQLiteStore) GetMessagesByChatID(chatID string, limit int, offset int)
 
 func (s *SQLiteStore) GetLastNMessagesByChatID(chatID string, n int) ([]Message, error) {
 	query := `
-        SELECT id, chat_id, sender, content, timestamp, negative_feedback
+        SELECT id, chat_id, sender, content, timestamp, negative_feedback, tool_call, parent_message_id, branch_id, partial, deleted, scheduled_at
         FROM messages
         WHERE chat_id = ?
         ORDER BY timestamp DESC
@@ -233,15 +519,120 @@ func (s *SQLiteStore) GetLastNMessagesByChatID(chatID string, n int) ([]Message,
 	var messages []Message
 	for rows.Next() {
 		var msg Message
-		if err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Sender, &msg.Content, &msg.Timestamp, &msg.NegativeFeedback); err != nil {
+		var toolCall sql.NullString
+		var parentMessageID sql.NullString
+		var scheduledAt sql.NullTime
+		if err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Sender, &msg.Content, &msg.Timestamp, &msg.NegativeFeedback, &toolCall, &parentMessageID, &msg.BranchID, &msg.Partial, &msg.Deleted, &scheduledAt); err != nil {
 			return nil, fmt.Errorf("failed to scan message row: %w", err)
 		}
+		if toolCall.Valid {
+			msg.ToolCall = &toolCall.String
+		}
+		if parentMessageID.Valid {
+			msg.ParentMessageID = &parentMessageID.String
+		}
+		if scheduledAt.Valid {
+			msg.ScheduledAt = &scheduledAt.Time
+		}
+		applyTombstone(&msg)
 		messages = append(messages, msg)
 	}
 
 	return messages, nil
 }
 
+// GetMessagesByBranch reconstructs the full linear transcript for a branch by
+// starting at its most recent message and following parent_message_id back
+// to the root. Messages created before the branch forked naturally carry an
+// earlier branch_id, but are still picked up by this walk. Scheduled
+// messages haven't been promoted into the tree yet, so they're excluded.
+func (s *SQLiteStore) GetMessagesByBranch(chatID, branchID string) ([]Message, error) {
+	all, err := s.GetMessagesByChatID(chatID, allMessagesLimit, 0, false)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]Message, len(all))
+	var head *Message
+	for i := range all {
+		m := all[i]
+		byID[m.ID] = m
+		if m.BranchID == branchID && (head == nil || m.Timestamp.After(head.Timestamp)) {
+			h := m
+			head = &h
+		}
+	}
+	if head == nil {
+		return nil, nil // Branch not found, or has no messages
+	}
+
+	var chain []Message
+	for cur := head; cur != nil; {
+		chain = append(chain, *cur)
+		if cur.ParentMessageID == nil {
+			break
+		}
+		parent, ok := byID[*cur.ParentMessageID]
+		if !ok {
+			break
+		}
+		cur = &parent
+	}
+
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// GetLastNMessagesByBranch returns up to the last n messages of a branch's
+// reconstructed transcript, in chronological order.
+func (s *SQLiteStore) GetLastNMessagesByBranch(chatID, branchID string, n int) ([]Message, error) {
+	chain, err := s.GetMessagesByBranch(chatID, branchID)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) > n {
+		chain = chain[len(chain)-n:]
+	}
+	return chain, nil
+}
+
+// GetBranches lists every branch of a chat's message tree, identified by the
+// earliest message created under each branch_id, for a lightweight UI branch
+// switcher.
+func (s *SQLiteStore) GetBranches(chatID string) ([]Branch, error) {
+	// SQLite assigns bare columns the values from the row that produced the
+	// single MIN()/MAX() aggregate in the result set, so this picks each
+	// branch's earliest message without a correlated subquery.
+	query := `
+        SELECT branch_id, id, content, MIN(timestamp) AS first_ts
+        FROM messages
+        WHERE chat_id = ?
+        GROUP BY branch_id
+        ORDER BY first_ts ASC
+    `
+	rows, err := s.db.Query(query, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query branches: %w", err)
+	}
+	defer rows.Close()
+
+	var branches []Branch
+	for rows.Next() {
+		var b Branch
+		var firstTS time.Time
+		if err := rows.Scan(&b.BranchID, &b.RootMessageID, &b.TitleFragment, &firstTS); err != nil {
+			return nil, fmt.Errorf("failed to scan branch row: %w", err)
+		}
+		if len(b.TitleFragment) > branchTitleFragmentLen {
+			b.TitleFragment = b.TitleFragment[:branchTitleFragmentLen] + "..."
+		}
+		branches = append(branches, b)
+	}
+	return branches, nil
+}
+
 func (s *SQLiteStore) UpdateMessageFeedback(messageID string, negativeFeedback bool) error {
 	stmt, err := s.db.Prepare("UPDATE messages SET negative_feedback = ? WHERE id = ?")
 	if err != nil {
@@ -260,6 +651,134 @@ func (s *SQLiteStore) UpdateMessageFeedback(messageID string, negativeFeedback b
 	return nil
 }
 
+// DeleteMessage soft-deletes messageID, scoped to chats owned by userID so a
+// message can't be deleted by anyone other than its chat's owner. The row
+// itself (and its parent_message_id link) is kept; GetMessageByID and the
+// GetMessagesBy* readers substitute tombstone content for it going forward.
+func (s *SQLiteStore) DeleteMessage(messageID string, userID int64) error {
+	stmt, err := s.db.Prepare(`
+        UPDATE messages
+        SET deleted = 1
+        WHERE id = ? AND chat_id IN (SELECT id FROM chats WHERE user_id = ?)
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to prepare message delete: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(messageID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to execute message delete: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("message not found or not owned by user")
+	}
+	return nil
+}
+
+// CreateScheduledMessage stores msg for delivery at deliverAt. msg has no
+// ParentMessageID/BranchID yet; MessageScheduler resolves both once it's
+// actually promoted, so the message threads onto whatever branch is current
+// at delivery time rather than the one current when it was scheduled.
+func (s *SQLiteStore) CreateScheduledMessage(msg *Message, deliverAt time.Time) error {
+	msg.ID = uuid.NewString()
+	msg.Timestamp = time.Now()
+	msg.ScheduledAt = &deliverAt
+
+	stmt, err := s.db.Prepare("INSERT INTO messages (id, chat_id, sender, content, timestamp, negative_feedback, scheduled_at) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare scheduled message insert: %w", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(msg.ID, msg.ChatID, msg.Sender, msg.Content, msg.Timestamp, msg.NegativeFeedback, deliverAt)
+	if err != nil {
+		return fmt.Errorf("failed to execute scheduled message insert: %w", err)
+	}
+	return nil
+}
+
+// MessagesDue returns every scheduled message whose ScheduledAt is at or
+// before now, across all chats, for MessageScheduler to promote.
+func (s *SQLiteStore) MessagesDue(now time.Time) ([]Message, error) {
+	query := "SELECT id, chat_id, sender, content, timestamp, negative_feedback, tool_call, parent_message_id, branch_id, partial, deleted, scheduled_at FROM messages WHERE scheduled_at IS NOT NULL AND scheduled_at <= ? ORDER BY scheduled_at ASC"
+	rows, err := s.db.Query(query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var msg Message
+		var toolCall sql.NullString
+		var parentMessageID sql.NullString
+		var scheduledAt sql.NullTime
+		if err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Sender, &msg.Content, &msg.Timestamp, &msg.NegativeFeedback, &toolCall, &parentMessageID, &msg.BranchID, &msg.Partial, &msg.Deleted, &scheduledAt); err != nil {
+			return nil, fmt.Errorf("failed to scan due message row: %w", err)
+		}
+		if toolCall.Valid {
+			msg.ToolCall = &toolCall.String
+		}
+		if parentMessageID.Valid {
+			msg.ParentMessageID = &parentMessageID.String
+		}
+		if scheduledAt.Valid {
+			msg.ScheduledAt = &scheduledAt.Time
+		}
+		applyTombstone(&msg)
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// CancelScheduledMessage deletes a scheduled message outright, scoped to
+// chats owned by userID. A scheduled message never entered the conversation
+// tree, so unlike DeleteMessage this removes the row rather than tombstoning
+// it.
+func (s *SQLiteStore) CancelScheduledMessage(messageID string, userID int64) error {
+	stmt, err := s.db.Prepare(`
+        DELETE FROM messages
+        WHERE id = ? AND scheduled_at IS NOT NULL AND chat_id IN (SELECT id FROM chats WHERE user_id = ?)
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to prepare scheduled message delete: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(messageID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to execute scheduled message delete: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("scheduled message not found or not owned by user")
+	}
+	return nil
+}
+
+// PromoteScheduledMessage threads a scheduled message onto parentMessageID
+// and branchID and clears its ScheduledAt, so it joins the conversation tree
+// as a live message.
+func (s *SQLiteStore) PromoteScheduledMessage(messageID string, parentMessageID *string, branchID string) error {
+	stmt, err := s.db.Prepare("UPDATE messages SET parent_message_id = ?, branch_id = ?, scheduled_at = NULL WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("failed to prepare scheduled message promotion: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(parentMessageID, branchID, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to execute scheduled message promotion: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("scheduled message not found")
+	}
+	return nil
+}
+
 // DataChunk methods (for RAG)
 func (s *SQLiteStore) createDataChunk(chunk *DataChunk) error {
 	embeddingBytes, err := json.Marshal(chunk.Embedding)
@@ -279,6 +798,148 @@ func (s *SQLiteStore) createDataChunk(chunk *DataChunk) error {
 		return fmt.Errorf("failed to execute data_chunk insert: %w", err)
 	}
 	chunk.ID, _ = res.LastInsertId()
+
+	if len(chunk.Embedding) > 0 {
+		s.vecIndex.Insert(chunk.ID, chunk.Embedding)
+	}
+
+	if _, err := s.db.Exec("INSERT INTO data_chunks_fts (rowid, content) VALUES (?, ?)", chunk.ID, chunk.Content); err != nil {
+		return fmt.Errorf("failed to index data_chunk %d for full-text search: %w", chunk.ID, err)
+	}
+	return nil
+}
+
+// getDataChunkByID fetches a single chunk's content by ID, for resolving the
+// hits SearchChunks gets back from the vector index.
+func (s *SQLiteStore) getDataChunkByID(id int64) (*DataChunk, error) {
+	var chunk DataChunk
+	err := s.db.QueryRow("SELECT id, content FROM data_chunks WHERE id = ?", id).Scan(&chunk.ID, &chunk.Content)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get data_chunk %d: %w", id, err)
+	}
+	return &chunk, nil
+}
+
+// SearchChunks returns up to k data chunks whose embedding is most similar to
+// queryEmbedding, using the in-memory HNSW index rather than scanning every
+// row. Chunks scoring below minScore are dropped.
+func (s *SQLiteStore) SearchChunks(queryEmbedding []float32, k int, minScore float32) ([]ScoredChunk, error) {
+	hits := s.vecIndex.Search(queryEmbedding, k, hnswDefaultEf)
+
+	scored := make([]ScoredChunk, 0, len(hits))
+	for _, hit := range hits {
+		similarity := 1 - hit.dist
+		if similarity < minScore {
+			continue
+		}
+		chunk, err := s.getDataChunkByID(hit.id)
+		if err != nil {
+			return nil, err
+		}
+		if chunk == nil {
+			continue // stale index entry for a since-deleted chunk
+		}
+		scored = append(scored, ScoredChunk{Chunk: *chunk, Similarity: similarity})
+	}
+	return scored, nil
+}
+
+// SearchChunksBM25 returns up to k data chunks ranked by lexical match
+// against query, using the FTS5 virtual table rather than the HNSW index.
+// This catches keyword-heavy queries (proper nouns, acronyms) that
+// cosine similarity over embeddings alone tends to rank poorly; callers
+// combine it with SearchChunks via Reciprocal Rank Fusion rather than using
+// either list on its own.
+func (s *SQLiteStore) SearchChunksBM25(query string, k int) ([]ScoredChunk, error) {
+	rows, err := s.db.Query(`
+		SELECT data_chunks.id, data_chunks.content, bm25(data_chunks_fts) AS score
+		FROM data_chunks_fts
+		JOIN data_chunks ON data_chunks.id = data_chunks_fts.rowid
+		WHERE data_chunks_fts MATCH ?
+		ORDER BY score ASC
+		LIMIT ?
+	`, query, k)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run full-text search: %w", err)
+	}
+	defer rows.Close()
+
+	var scored []ScoredChunk
+	for rows.Next() {
+		var chunk DataChunk
+		var score float64
+		if err := rows.Scan(&chunk.ID, &chunk.Content, &score); err != nil {
+			return nil, fmt.Errorf("failed to scan full-text search row: %w", err)
+		}
+		// bm25() scores are negative, with smaller (more negative) meaning a
+		// better match; negate so higher Similarity still means "more
+		// relevant", matching SearchChunks' convention even though the two
+		// scores aren't on the same scale.
+		scored = append(scored, ScoredChunk{Chunk: chunk, Similarity: float32(-score)})
+	}
+	return scored, rows.Err()
+}
+
+// VectorIndexSize reports how many chunks are currently indexed for ANN
+// search, mainly so callers can log a useful warning on an empty index.
+func (s *SQLiteStore) VectorIndexSize() int {
+	s.vecIndex.mu.RLock()
+	defer s.vecIndex.mu.RUnlock()
+	return len(s.vecIndex.nodes)
+}
+
+// saveVectorIndex persists the full in-memory HNSW graph as a single JSON
+// blob, so it doesn't need to be rebuilt chunk-by-chunk on the next restart.
+func (s *SQLiteStore) saveVectorIndex() error {
+	s.vecIndex.mu.RLock()
+	persisted := hnswPersisted{
+		Nodes:      s.vecIndex.nodes,
+		EntryPoint: s.vecIndex.entryPoint,
+		MaxLevel:   s.vecIndex.maxLevel,
+	}
+	s.vecIndex.mu.RUnlock()
+
+	data, err := json.Marshal(persisted)
+	if err != nil {
+		return fmt.Errorf("failed to marshal vector index: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO data_chunks_vec_index (id, graph_json) VALUES (1, ?)
+		ON CONFLICT(id) DO UPDATE SET graph_json = excluded.graph_json
+	`, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to persist vector index: %w", err)
+	}
+	return nil
+}
+
+// loadVectorIndex restores the HNSW graph persisted by saveVectorIndex, if
+// any. A missing sidecar row just means ingestion hasn't run yet; the index
+// is built fresh on the next IngestDataFromFile.
+func (s *SQLiteStore) loadVectorIndex() error {
+	var graphJSON string
+	err := s.db.QueryRow("SELECT graph_json FROM data_chunks_vec_index WHERE id = 1").Scan(&graphJSON)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load vector index: %w", err)
+	}
+
+	var persisted hnswPersisted
+	if err := json.Unmarshal([]byte(graphJSON), &persisted); err != nil {
+		return fmt.Errorf("failed to unmarshal vector index: %w", err)
+	}
+
+	idx := newHNSWIndex()
+	idx.nodes = persisted.Nodes
+	idx.entryPoint = persisted.EntryPoint
+	idx.maxLevel = persisted.MaxLevel
+	s.vecIndex = idx
 	return nil
 }
 
@@ -313,6 +974,28 @@ func (s *SQLiteStore) GetAllDataChunks() ([]DataChunk, error) {
 	return chunks, nil
 }
 
+// GetRandomDataChunks samples up to k data chunks uniformly at random, for
+// features like prompt-starter generation that just need a representative
+// slice of the corpus rather than a similarity search.
+func (s *SQLiteStore) GetRandomDataChunks(k int) ([]DataChunk, error) {
+	rows, err := s.db.Query("SELECT id, content, embedding_json FROM data_chunks ORDER BY RANDOM() LIMIT ?", k)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query random data_chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []DataChunk
+	for rows.Next() {
+		var chunk DataChunk
+		var embeddingJSON string
+		if err := rows.Scan(&chunk.ID, &chunk.Content, &embeddingJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan data_chunk row: %w", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
 func (s *SQLiteStore) ClearDataChunks() error {
 	_, err := s.db.Exec("DELETE FROM data_chunks")
 	if err != nil {
@@ -322,100 +1005,25 @@ func (s *SQLiteStore) ClearDataChunks() error {
 	if err != nil && !strings.Contains(err.Error(), "no such table") {
 		log.Printf("Warning: could not reset sequence for data_chunks: %v", err)
 	}
+
+	s.vecIndex = newHNSWIndex()
+	if _, err := s.db.Exec("DELETE FROM data_chunks_vec_index"); err != nil {
+		log.Printf("Warning: could not clear persisted vector index: %v", err)
+	}
+	if _, err := s.db.Exec("DELETE FROM data_chunks_fts"); err != nil {
+		log.Printf("Warning: could not clear full-text search index: %v", err)
+	}
 	return nil
 }
 
 // IngestDataFromFile reads data.md, extracts text, generates embeddings, and stores them.
 func (s *SQLiteStore) IngestDataFromFile(filePath string, embedder func(string) ([]float32, error)) (int, error) {
-	contentBytes, err := os.ReadFile(filePath)
+	rawChunks, err := readDataFile(filePath)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read data file %s: %w", filePath, err)
+		return 0, err
 	}
-	fileContent := string(contentBytes)
-	lines := strings.Split(fileContent, "\n")
-
-	var rawChunks []string
-	for i, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		if trimmedLine == "" {
-			continue // Skip empty lines
-		}
-
-		// Skip table header and separator
-		if i == 0 && strings.Contains(trimmedLine, "|") && (strings.Contains(strings.ToLower(trimmedLine), "text") || strings.Contains(strings.ToLower(trimmedLine), "content")) {
-			log.Printf("Skipping table header: %s", trimmedLine)
-			continue
-		}
-		if i == 1 && strings.Contains(trimmedLine, "|") && strings.Contains(trimmedLine, "---") {
-			log.Printf("Skipping table separator: %s", trimmedLine)
-			continue
-		}
-
-		// Basic parsing for a single-column Markdown table row: | some content |
-		if strings.HasPrefix(trimmedLine, "|") && strings.HasSuffix(trimmedLine, "|") {
-			parts := strings.Split(trimmedLine, "|")
-			// Expect 3 parts: "" (before first |), " content ", "" (after last |)
-			// Or for | text | header, parts would be ["", " text ", ""]
-			if len(parts) >= 3 { // At least | content |
-				// The actual content is the second element after splitting by '|', then trim spaces.
-				// Example: "| some content |" -> parts are ["", " some content ", ""]
-				// Example: "|text|" -> parts are ["", "text", ""]
-				// We take parts[1] which is " some content " and trim it.
-				cellContent := strings.TrimSpace(parts[1])
-				if cellContent != "" {
-					rawChunks = append(rawChunks, cellContent)
-				} else {
-					log.Printf("Skipping row with empty cell content: %s", trimmedLine)
-				}
-			} else {
-				log.Printf("Skipping malformed table row (not enough '|'): %s", trimmedLine)
-			}
-		} else {
-			// If it's not a table row after the header, skip it.
-			if i > 1 { // Only log if we're past the typical header/separator lines
-				log.Printf("Skipping line not matching table row format: %s", trimmedLine)
-			}
-		}
-	}
-
 	if len(rawChunks) == 0 {
-		log.Println("No chunks generated from data file. Ensure it's a Markdown table with a 'text' column and content.")
-		return 0, nil // Or an error if this is unexpected
-	}
-
-	log.Printf("Generated %d raw chunks from table. Now embedding (this may take a while)...", len(rawChunks))
-
-	if err := s.ClearDataChunks(); err != nil {
-		return 0, fmt.Errorf("failed to clear existing data chunks: %w", err)
-	}
-
-	count := 0
-
-	ticker := time.NewTicker(40 * time.Millisecond) // delay to not hit rate limit (1500/min)
-	defer ticker.Stop()
-
-	for i, rawChunk := range rawChunks {
-		<-ticker.C
-
-		embedding, err := embedder(rawChunk)
-		if err != nil {
-			log.Printf("Failed to generate embedding for chunk %d (\"%.50s...\"): %v. Skipping.", i+1, rawChunk, err)
-			continue
-		}
-
-		chunk := DataChunk{
-			Content:   rawChunk,
-			Embedding: embedding,
-		}
-		if err := s.createDataChunk(&chunk); err != nil {
-			log.Printf("Failed to store data chunk %d: %v. Skipping.", i+1, err)
-			continue
-		}
-		count++
-		if count%10 == 0 || count == len(rawChunks) {
-			log.Printf("Ingested %d/%d chunks...", count, len(rawChunks))
-		}
+		return 0, nil
 	}
-	log.Printf("Successfully ingested %d chunks.", count)
-	return count, nil
+	return ingestChunks(rawChunks, embedder, s.ClearDataChunks, s.createDataChunk, s.saveVectorIndex)
 }
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Store is the persistence interface the rest of the app programs against.
+// SQLiteStore and PostgresStore both implement it, so a single-instance
+// deployment can run against an embedded SQLite file while a multi-instance
+// one points DatabaseDriver at a shared Postgres database, with no changes
+// above this package.
+type Store interface {
+	Close() error
+
+	GetUserByExternalID(externalUserID string) (*User, error)
+	// GetUserByID looks up a user by internal ID, for admin endpoints
+	// operating on an arbitrary user rather than the caller's own account.
+	GetUserByID(userID int64) (*User, error)
+	// CreateUser inserts a new user, promoting it to RoleAdmin if it's the
+	// very first user ever created ("host" bootstrapping).
+	CreateUser(externalUserID, passwordHash string) (*User, error)
+	// GetUsers returns up to limit users, newest first, starting after
+	// cursor (empty cursor starts from the newest user), for the admin user
+	// list.
+	GetUsers(limit int, cursor string) ([]User, string, error)
+	// SetUserDisabled flips a user's Disabled flag.
+	SetUserDisabled(userID int64, disabled bool) error
+	// SetUserPasswordHash overwrites a user's password hash, e.g. for an
+	// admin-initiated password reset.
+	SetUserPasswordHash(userID int64, passwordHash string) error
+	// BumpUserTokenVersion increments a user's TokenVersion, invalidating
+	// every JWT issued before the call.
+	BumpUserTokenVersion(userID int64) error
+
+	// CreateSession inserts a new session row for a freshly issued refresh
+	// token, returning it with its generated ID and CreatedAt populated.
+	CreateSession(userID int64, refreshTokenHash, userAgent, ip string, expiresAt time.Time) (*Session, error)
+	// GetSessionByID looks up a session by ID, or returns nil if it doesn't
+	// exist. It returns sessions regardless of revoked/expired status;
+	// callers decide what that means for the request at hand.
+	GetSessionByID(sessionID string) (*Session, error)
+	// RotateSessionRefreshToken overwrites a session's refresh token hash and
+	// expiry, e.g. every time RefreshHandler exchanges a refresh token for a
+	// new access token.
+	RotateSessionRefreshToken(sessionID, refreshTokenHash string, expiresAt time.Time) error
+	// RevokeSession marks a session revoked, rejecting any access token
+	// bearing its "sid" claim and any further use of its refresh token.
+	RevokeSession(sessionID string) error
+	// RevokeAllSessionsForUser revokes every session belonging to userID,
+	// e.g. for a "log out everywhere" action.
+	RevokeAllSessionsForUser(userID int64) error
+	// GetSessionsByUserID returns every session for userID, newest first, for
+	// a user to review their own active sessions.
+	GetSessionsByUserID(userID int64) ([]Session, error)
+
+	CreateChat(userID int64, title *string) (*Chat, error)
+	GetChatByID(chatID string, userID int64) (*Chat, error)
+	// GetChatsByUserID returns up to limit chats for userID, newest first,
+	// starting after cursor (empty cursor starts from the newest chat). It
+	// returns the cursor for the next page, or "" if there are no more chats.
+	GetChatsByUserID(userID int64, limit int, cursor string) ([]Chat, string, error)
+	GetChatOwnerID(chatID string) (int64, error)
+	UpdateChatCurrentBranch(chatID string, userID int64, branchID string) error
+	UpdateChatTitle(chatID string, userID int64, title string) error
+	// DeleteChat permanently removes a chat and all of its messages,
+	// regardless of owner. Used by admin endpoints; regular users have no
+	// equivalent since DeleteMessage's soft-delete is the only deletion a
+	// regular user gets.
+	DeleteChat(chatID string) error
+
+	CreateMessage(msg *Message) error
+	GetMessageByID(messageID string) (*Message, error)
+	GetMessagesByChatID(chatID string, limit int, offset int, includeScheduled bool) ([]Message, error)
+	GetLastNMessagesByChatID(chatID string, n int) ([]Message, error)
+	GetMessagesByBranch(chatID, branchID string) ([]Message, error)
+	GetLastNMessagesByBranch(chatID, branchID string, n int) ([]Message, error)
+	GetBranches(chatID string) ([]Branch, error)
+	UpdateMessageFeedback(messageID string, negativeFeedback bool) error
+	DeleteMessage(messageID string, userID int64) error
+
+	// CreateScheduledMessage stores msg (a user message with no
+	// ParentMessageID/BranchID yet) for delivery at deliverAt, for
+	// MessageScheduler to later promote with MessagesDue.
+	CreateScheduledMessage(msg *Message, deliverAt time.Time) error
+	// MessagesDue returns every scheduled message whose ScheduledAt is at or
+	// before now, across all chats, for MessageScheduler to promote.
+	MessagesDue(now time.Time) ([]Message, error)
+	// CancelScheduledMessage deletes a scheduled message outright, scoped to
+	// chats owned by userID. Unlike DeleteMessage, this isn't a soft delete:
+	// a cancelled scheduled message never entered the conversation tree, so
+	// there's nothing worth tombstoning.
+	CancelScheduledMessage(messageID string, userID int64) error
+	// PromoteScheduledMessage threads a scheduled message onto parentMessageID
+	// and branchID and clears its ScheduledAt, so it joins the conversation
+	// tree as a live message. The branch is resolved at delivery time, not
+	// schedule time, so it threads onto whatever's current then.
+	PromoteScheduledMessage(messageID string, parentMessageID *string, branchID string) error
+
+	SearchChunks(queryEmbedding []float32, k int, minScore float32) ([]ScoredChunk, error)
+	SearchChunksBM25(query string, k int) ([]ScoredChunk, error)
+	VectorIndexSize() int
+	GetAllDataChunks() ([]DataChunk, error)
+	GetRandomDataChunks(k int) ([]DataChunk, error)
+	ClearDataChunks() error
+	IngestDataFromFile(filePath string, embedder func(string) ([]float32, error)) (int, error)
+}
+
+// NewStore opens a Store backed by driver ("sqlite3" or "postgres"), with
+// dsn interpreted as that driver's connection string.
+func NewStore(driver, dsn string) (Store, error) {
+	switch driver {
+	case "sqlite3", "sqlite":
+		return NewSQLiteStore(dsn)
+	case "postgres", "postgresql":
+		return NewPostgresStore(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported store driver %q (expected sqlite3 or postgres)", driver)
+	}
+}